@@ -2,23 +2,46 @@ package main
 
 import (
 	"context"
+	"errors"
+	"flag"
 	"log/slog"
 	"net/http"
 	"time"
 
 	"github.com/Caritas-Team/reviewer/internal/config"
+	"github.com/Caritas-Team/reviewer/internal/export"
 	"github.com/Caritas-Team/reviewer/internal/handler"
+	"github.com/Caritas-Team/reviewer/internal/lifecycle"
 	"github.com/Caritas-Team/reviewer/internal/logger"
 	"github.com/Caritas-Team/reviewer/internal/memecached"
 	"github.com/Caritas-Team/reviewer/internal/metrics"
+	"github.com/Caritas-Team/reviewer/internal/storage"
+	"github.com/Caritas-Team/reviewer/internal/usecase/file"
+	"github.com/Caritas-Team/reviewer/internal/usecase/user"
 )
 
 func main() {
-	cfg, err := config.Load()
+	configPath := flag.String("config", "", "путь к файлу конфигурации; по умолчанию $"+config.EnvConfigPath+" или cfg/config.yml")
+	flag.Parse()
+
+	watcher, err := config.NewWatcher(*configPath)
 	if err != nil {
 		slog.Error("config load error", "err", err)
 		return
 	}
+	cfg := watcher.Current()
+
+	logger.InitGlobalLogger(cfg)
+
+	registry, err := metrics.NewRegistry(metrics.Options{
+		DurationBuckets: cfg.Metrics.DurationBuckets,
+		SizeBuckets:     cfg.Metrics.SizeBuckets,
+	})
+	if err != nil {
+		slog.Error("metrics initialization failed", "err", err)
+		return
+	}
+	metrics.SetDefault(registry)
 
 	background := context.Background()
 	cache, err := memecached.NewCache(background, cfg)
@@ -26,34 +49,76 @@ func main() {
 		slog.Error("cache initialization failed", "err", err)
 		return
 	}
-	defer func(cache *memecached.Cache) {
-		err := cache.Close()
-		if err != nil {
-			slog.Error("cache close error", "err", err)
-		}
-	}(cache)
 
-	if cache.IsHealthy(background) {
+	if cfg.Memcached.Enable && !cache.Degraded() {
 		slog.Info("Memcached is healthy")
-	} else {
-		slog.Warn("Memcached is unavailable")
 	}
 
+	operations := storage.NewOperationStore(cfg, cache)
+
+	scheduler := file.NewScheduler(cfg.Files, operations, file.Chain(
+		file.ProcessorFunc(file.ExtractReport),
+		file.ProcessorFunc(export.RenderResult),
+	))
+	scheduler.Start(background)
+
+	idempotency := user.NewIdempotency(cfg, cache)
+
+	api, err := handler.NewHandler(cfg, operations, scheduler, idempotency)
+	if err != nil {
+		slog.Error("handler initialization failed", "err", err)
+		return
+	}
+
+	limiter := user.NewRateLimiter(cfg, cache)
+
 	mux := http.NewServeMux()
 	mux.HandleFunc("/ping", func(w http.ResponseWriter, r *http.Request) {
 		_, _ = w.Write([]byte("pong"))
 	})
+	mux.Handle("POST /upload", limiter.Middleware(http.HandlerFunc(api.Upload)))
+	mux.Handle("GET /status", limiter.Middleware(http.HandlerFunc(api.Status)))
+	mux.Handle("GET /get", limiter.Middleware(http.HandlerFunc(api.Status))) // устаревшее имя из текста ТЗ
+	mux.Handle("GET /export", limiter.Middleware(http.HandlerFunc(api.Export)))
 
-	h := handler.CORS(handler.CORSConfig{
-		AllowedOrigins:   cfg.CORS.AllowedOrigins,
-		AllowedMethods:   cfg.CORS.AllowedMethods,
-		AllowedHeaders:   cfg.CORS.AllowedHeaders,
-		AllowCredentials: true, // вынести в config.yaml при надобности
-		MaxAgeSeconds:    3600, // вынести в config.yaml при надобности
-	})(mux)
+	// Метрики — на основном mux или на отдельном внутреннем адресе, не доступном снаружи
+	var metricsSrv *http.Server
+	if cfg.Metrics.Enabled {
+		path := metricsPath(cfg.Metrics)
+		if cfg.Metrics.ListenAddr == "" {
+			mux.Handle("GET "+path, registry.Handler())
+		} else {
+			metricsMux := http.NewServeMux()
+			metricsMux.Handle("GET "+path, registry.Handler())
+			metricsSrv = &http.Server{
+				Addr:              cfg.Metrics.ListenAddr,
+				Handler:           metricsMux,
+				ReadHeaderTimeout: 10 * time.Second,
+			}
+		}
+	}
 
-	metrics.InitMetrics()
-	logger.InitGlobalLogger(cfg)
+	corsPolicy, err := handler.NewCORSPolicy(corsConfig(cfg))
+	if err != nil {
+		slog.Error("cors initialization failed", "err", err)
+		return
+	}
+	var instrument func(http.Handler) http.Handler
+	if cfg.Metrics.Enabled {
+		instrument = handler.Instrument(cfg.Metrics.MaxIPs)
+	}
+	h := handler.Stack(mux, corsPolicy, instrument)
+
+	// Изменения, применяемые на лету; остальные ключи требуют перезапуска (см. config.Watcher)
+	watcher.Subscribe(func(cfg config.Config) {
+		logger.GlobalLogger.SetLevel(cfg.Logging.Level)
+		if err := corsPolicy.Update(corsConfig(cfg)); err != nil {
+			slog.Error("cors reload failed, keeping current policy", "err", err)
+		}
+		limiter.Update(cfg.RateLimiter)
+		api.UpdateFiles(cfg.Files)
+	})
+	watcher.Start()
 
 	srv := &http.Server{
 		Addr:         cfg.Server.Addr(),
@@ -63,8 +128,114 @@ func main() {
 		IdleTimeout:  5 * time.Minute,
 	}
 
-	if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-		slog.Error("server start failed", "err", err)
+	// Порядок остановки: новые загрузки → HTTP-запросы → очередь обработки → кэш
+	lc := lifecycle.NewManager(cfg.Server.ShutdownTimeout())
+	lc.OnShutdown("uploads", func(context.Context) error {
+		api.StopUploads()
+		return nil
+	})
+	lc.OnShutdown("http server", srv.Shutdown)
+	lc.OnShutdown("scheduler", scheduler.Shutdown)
+	if metricsSrv != nil {
+		go func() {
+			if err := metricsSrv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				slog.Error("metrics server failed", "addr", metricsSrv.Addr, "err", err)
+			}
+		}()
+		lc.OnShutdown("metrics server", metricsSrv.Shutdown)
+	}
+	if cfg.Metrics.Enabled {
+		collectorCtx, stopCollector := context.WithCancel(background)
+		collected := make(chan struct{})
+		go func() {
+			defer close(collected)
+			metrics.NewRuntimeCollector(cfg.Metrics.CollectInterval()).Run(collectorCtx)
+		}()
+		lc.OnShutdown("runtime collector", func(ctx context.Context) error {
+			stopCollector()
+			select {
+			case <-collected:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		})
+	}
+	healthCtx, stopHealth := context.WithCancel(background)
+	checked := make(chan struct{})
+	go func() {
+		defer close(checked)
+		cache.Run(healthCtx)
+	}()
+	lc.OnShutdown("cache health check", func(ctx context.Context) error {
+		stopHealth()
+		select {
+		case <-checked:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	})
+	retentionCtx, stopRetention := context.WithCancel(background)
+	swept := make(chan struct{})
+	go func() {
+		defer close(swept)
+		file.NewRetention(cfg, operations).Run(retentionCtx)
+	}()
+	lc.OnShutdown("file retention", func(ctx context.Context) error {
+		stopRetention()
+		select {
+		case <-swept:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	})
+	lc.OnShutdown("cache", func(context.Context) error { return cache.Close() })
+
+	err = lc.Run(background, func() error {
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		slog.Error("server stopped with error", "err", err)
 		return
 	}
+	slog.Info("server stopped")
+}
+
+// corsConfig собирает политику CORS; метрики на основном порту закрыты для кросс-доменного доступа
+func corsConfig(cfg config.Config) handler.CORSConfig {
+	c := cfg.CORS
+	routes := make([]handler.CORSRoute, 0, len(c.Routes)+1)
+	for _, route := range c.Routes {
+		routes = append(routes, handler.CORSRoute{
+			Path:           route.Path,
+			Deny:           route.Deny,
+			AllowedOrigins: route.AllowedOrigins,
+			AllowedMethods: route.AllowedMethods,
+		})
+	}
+	// Из маршрутов с одинаковым путём действует первый, поэтому явное правило для метрик важнее
+	if cfg.Metrics.Enabled && cfg.Metrics.ListenAddr == "" {
+		routes = append(routes, handler.CORSRoute{Path: metricsPath(cfg.Metrics), Deny: true})
+	}
+	return handler.CORSConfig{
+		AllowedOrigins:   c.AllowedOrigins,
+		AllowedMethods:   c.AllowedMethods,
+		AllowedHeaders:   c.AllowedHeaders,
+		ExposedHeaders:   c.ExposedHeaders,
+		AllowCredentials: c.AllowCredentials,
+		MaxAgeSeconds:    c.MaxAgeSeconds,
+		Routes:           routes,
+	}
+}
+
+func metricsPath(cfg config.Metrics) string {
+	if cfg.Path == "" {
+		return "/metrics"
+	}
+	return cfg.Path
 }
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Caritas-Team/reviewer/internal/config"
+	"github.com/Caritas-Team/reviewer/internal/handler"
+	"github.com/spf13/viper"
+)
+
+// apiHeaders — заголовки запросов, которые фронтенд шлёт к API
+const apiHeaders = "content-type,idempotency-key,x-operation-key,x-request-uuid"
+
+func TestShippedCORSAllowsAPIHeaders(t *testing.T) {
+	shipped, err := config.Load("../../cfg/config.yml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	example := viper.New()
+	example.SetConfigFile("../../cfg/config.cors.example.yaml")
+	if err := example.ReadInConfig(); err != nil {
+		t.Fatal(err)
+	}
+	var exampleCORS config.CORS
+	if err := example.UnmarshalKey("cors", &exampleCORS); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		cfg    config.Config
+		origin string
+		method string
+		path   string
+	}{
+		{name: "config.yml upload", cfg: shipped, origin: "https://localhost:8080", method: http.MethodPost, path: "/upload"},
+		{name: "config.yml status", cfg: shipped, origin: "https://localhost:8080", method: http.MethodGet, path: "/status"},
+		{name: "example upload", cfg: config.Config{CORS: exampleCORS}, origin: "https://app.example.com", method: http.MethodPost, path: "/upload"},
+		{name: "example status", cfg: config.Config{CORS: exampleCORS}, origin: "http://localhost:3000", method: http.MethodGet, path: "/status"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policy, err := handler.NewCORSPolicy(corsConfig(tt.cfg))
+			if err != nil {
+				t.Fatal(err)
+			}
+			h := policy.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				t.Errorf("preflight reached the handler")
+			}))
+
+			r := httptest.NewRequest(http.MethodOptions, tt.path, nil)
+			r.Header.Set("Origin", tt.origin)
+			r.Header.Set("Access-Control-Request-Method", tt.method)
+			r.Header.Set("Access-Control-Request-Headers", apiHeaders)
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+
+			if got := w.Header().Get("Access-Control-Allow-Origin"); got != tt.origin {
+				t.Fatalf("Access-Control-Allow-Origin = %q, want %q (status %d)", got, tt.origin, w.Code)
+			}
+			if got := w.Header().Get("Access-Control-Allow-Headers"); got != apiHeaders {
+				t.Errorf("Access-Control-Allow-Headers = %q, want %q", got, apiHeaders)
+			}
+		})
+	}
+}
@@ -2,28 +2,49 @@ package config
 
 import (
 	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
 	"time"
 
 	"github.com/spf13/viper"
 )
 
 type Server struct {
-	Host            string `mapstructure:"host"`
-	Port            int    `mapstructure:"port"`
-	Debug           bool   `mapstructure:"debug"`
-	LogLevel        string `mapstructure:"log_level"`
-	ReadTimeoutSec  int    `mapstructure:"read_timeout"`
-	WriteTimeoutSec int    `mapstructure:"write_timeout"`
+	Host               string `mapstructure:"host"`
+	Port               int    `mapstructure:"port"`
+	Debug              bool   `mapstructure:"debug"`
+	LogLevel           string `mapstructure:"log_level"`
+	ReadTimeoutSec     int    `mapstructure:"read_timeout"`
+	WriteTimeoutSec    int    `mapstructure:"write_timeout"`
+	ShutdownTimeoutSec int    `mapstructure:"shutdown_timeout"`
 }
 
 func (s Server) Addr() string                { return fmt.Sprintf("%s:%d", s.Host, s.Port) }
 func (s Server) ReadTimeout() time.Duration  { return time.Duration(s.ReadTimeoutSec) * time.Second }
 func (s Server) WriteTimeout() time.Duration { return time.Duration(s.WriteTimeoutSec) * time.Second }
+func (s Server) ShutdownTimeout() time.Duration {
+	return time.Duration(s.ShutdownTimeoutSec) * time.Second
+}
 
 type CORS struct {
+	AllowedOrigins   []string    `mapstructure:"allowed_origins"`
+	AllowedMethods   []string    `mapstructure:"allowed_methods"`
+	AllowedHeaders   []string    `mapstructure:"allowed_headers"`
+	ExposedHeaders   []string    `mapstructure:"exposed_headers"`
+	AllowCredentials bool        `mapstructure:"allow_credentials"`
+	MaxAgeSeconds    int         `mapstructure:"max_age_seconds"`
+	Routes           []CORSRoute `mapstructure:"routes"`
+}
+
+// CORSRoute переопределяет CORS для пути (с "/" на конце — и для вложенных путей).
+// Deny запрещает кросс-доменный доступ; пустые списки берутся из общей политики.
+type CORSRoute struct {
+	Path           string   `mapstructure:"path"`
+	Deny           bool     `mapstructure:"deny"`
 	AllowedOrigins []string `mapstructure:"allowed_origins"`
 	AllowedMethods []string `mapstructure:"allowed_methods"`
-	AllowedHeaders []string `mapstructure:"allowed_headers"`
 }
 
 type RateLimiter struct {
@@ -33,10 +54,33 @@ type RateLimiter struct {
 }
 
 type Memcached struct {
-	Enable     bool     `mapstructure:"enable"`
-	Servers    []string `mapstructure:"servers"`
-	DefaultTTL int      `mapstructure:"default_ttl"`
-	KeyPrefix  string   `mapstructure:"key_prefix"`
+	Enable      bool       `mapstructure:"enable"`
+	Servers     []string   `mapstructure:"servers"`
+	DefaultTTL  int        `mapstructure:"default_ttl"`
+	KeyPrefix   string     `mapstructure:"key_prefix"`
+	ChunkSize   int        `mapstructure:"chunk_size"`
+	Compression string     `mapstructure:"compression"`
+	Encryption  Encryption `mapstructure:"encryption"`
+
+	ConnectAttempts   int `mapstructure:"connect_attempts"`
+	ConnectBackoffSec int `mapstructure:"connect_backoff"`
+	HealthIntervalSec int `mapstructure:"health_interval"`
+}
+
+func (m Memcached) ConnectBackoff() time.Duration {
+	return time.Duration(m.ConnectBackoffSec) * time.Second
+}
+
+func (m Memcached) HealthInterval() time.Duration {
+	return time.Duration(m.HealthIntervalSec) * time.Second
+}
+
+// Encryption — шифрование значений в memcached. Keys — base64-ключи AES (16, 24 или 32 байта)
+// по идентификаторам; новые записи шифруются ключом KeyID, старые читаются любым из Keys.
+type Encryption struct {
+	Enabled bool              `mapstructure:"enabled"`
+	KeyID   string            `mapstructure:"key_id"`
+	Keys    map[string]string `mapstructure:"keys"`
 }
 
 type Files struct {
@@ -44,11 +88,35 @@ type Files struct {
 	MaxFileSize        int64    `mapstructure:"max_file_size"`
 	MaxProcessingTime  int      `mapstructure:"max_processing_time"`
 	AllowedMIMETypes   []string `mapstructure:"allowed_mime_types"`
+	StorageDir         string   `mapstructure:"storage_dir"`
+	Workers            int      `mapstructure:"workers"`
+	QueueSize          int      `mapstructure:"queue_size"`
+}
+
+func (f Files) ProcessingTimeout() time.Duration {
+	return time.Duration(f.MaxProcessingTime) * time.Second
+}
+
+// Dir возвращает каталог загруженных файлов, по умолчанию — reviewer во временном каталоге
+func (f Files) Dir() string {
+	if f.StorageDir == "" {
+		return filepath.Join(os.TempDir(), "reviewer")
+	}
+	return f.StorageDir
 }
 
 type Metrics struct {
-	Enabled bool   `mapstructure:"enabled"`
-	Path    string `mapstructure:"path"`
+	Enabled            bool      `mapstructure:"enabled"`
+	Path               string    `mapstructure:"path"`
+	ListenAddr         string    `mapstructure:"listen_addr"`
+	MaxIPs             int       `mapstructure:"max_ips"`
+	CollectIntervalSec int       `mapstructure:"collect_interval"`
+	DurationBuckets    []float64 `mapstructure:"duration_buckets"`
+	SizeBuckets        []float64 `mapstructure:"size_buckets"`
+}
+
+func (m Metrics) CollectInterval() time.Duration {
+	return time.Duration(m.CollectIntervalSec) * time.Second
 }
 
 type Logging struct {
@@ -66,19 +134,91 @@ type Config struct {
 	Logging     Logging     `mapstructure:"logging"`
 }
 
-func Load() (Config, error) {
-	var cfg Config
+const (
+	// EnvPrefix — префикс переменных окружения: REVIEWER_SERVER_PORT переопределяет server.port
+	EnvPrefix = "REVIEWER"
+	// EnvConfigPath задаёт путь к файлу конфигурации, если не передан флаг --config
+	EnvConfigPath = EnvPrefix + "_CONFIG"
+)
 
+// Load читает конфигурацию из path, из REVIEWER_CONFIG или, если оба пусты, из cfg/config.yml.
+// Любой ключ переопределяется переменной REVIEWER_<СЕКЦИЯ>_<КЛЮЧ>; списки задаются через запятую,
+// элементы словарей — отдельными переменными, например REVIEWER_MEMCACHED_ENCRYPTION_KEYS_K1.
+// Результат проверяется Validate.
+func Load(path string) (Config, error) {
+	v, err := newViper(path)
+	if err != nil {
+		return Config{}, err
+	}
+	if err := v.ReadInConfig(); err != nil {
+		return Config{}, fmt.Errorf("read config: %w", err)
+	}
+	return decode(v)
+}
+
+func newViper(path string) (*viper.Viper, error) {
 	v := viper.New()
-	v.SetConfigName("config")
-	v.SetConfigType("yaml")
-	v.AddConfigPath("cfg")
+	if path == "" {
+		path = os.Getenv(EnvConfigPath)
+	}
+	if path != "" {
+		v.SetConfigFile(path)
+	} else {
+		v.SetConfigName("config")
+		v.SetConfigType("yaml")
+		v.AddConfigPath("cfg")
+	}
 
-	if err := v.ReadInConfig(); err != nil {
-		return cfg, fmt.Errorf("read config: %w", err)
+	v.SetEnvPrefix(EnvPrefix)
+	v.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
+	v.AutomaticEnv()
+	// AutomaticEnv не видит ключи, которых нет в файле, поэтому все ключи Config привязываются явно
+	if err := bindEnv(v, reflect.TypeFor[Config](), ""); err != nil {
+		return nil, fmt.Errorf("bind env: %w", err)
 	}
+	return v, nil
+}
+
+// decode разбирает прочитанную конфигурацию и проверяет её
+func decode(v *viper.Viper) (Config, error) {
+	var cfg Config
 	if err := v.Unmarshal(&cfg); err != nil {
 		return cfg, fmt.Errorf("unmarshal config: %w", err)
 	}
+	if err := cfg.Validate(); err != nil {
+		return cfg, fmt.Errorf("invalid config %s:\n%w", v.ConfigFileUsed(), err)
+	}
 	return cfg, nil
 }
+
+// bindEnv привязывает к переменным окружения все ключи по тегам mapstructure. Словарям
+// соответствуют переменные с префиксом ключа: каждая из них становится отдельным элементом.
+func bindEnv(v *viper.Viper, t reflect.Type, prefix string) error {
+	for i := range t.NumField() {
+		field := t.Field(i)
+		name := field.Tag.Get("mapstructure")
+		if name == "" {
+			continue
+		}
+		key := prefix + name
+		switch field.Type.Kind() {
+		case reflect.Struct:
+			if err := bindEnv(v, field.Type, key+"."); err != nil {
+				return err
+			}
+		case reflect.Map:
+			envPrefix := EnvPrefix + "_" + strings.ToUpper(strings.ReplaceAll(key, ".", "_")) + "_"
+			for _, env := range os.Environ() {
+				name, value, _ := strings.Cut(env, "=")
+				if id, ok := strings.CutPrefix(name, envPrefix); ok && id != "" {
+					v.Set(key+"."+strings.ToLower(id), value)
+				}
+			}
+		default:
+			if err := v.BindEnv(key); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testConfig = `
+server:
+  port: 8080
+  read_timeout: 10
+  write_timeout: 10
+  shutdown_timeout: 5
+files:
+  max_processing_time: 60
+  allowed_mime_types: ["application/pdf"]
+memcached:
+  enable: false
+  default_ttl: 300
+  encryption:
+    key_id: "k1"
+    keys:
+      k1: "from-file"
+`
+
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yml")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadEnvOverrides(t *testing.T) {
+	path := writeConfig(t, testConfig)
+	t.Setenv("REVIEWER_SERVER_PORT", "9000")
+	// Ключа нет в файле — переопределение всё равно применяется
+	t.Setenv("REVIEWER_METRICS_LISTEN_ADDR", ":9090")
+	t.Setenv("REVIEWER_FILES_ALLOWED_MIME_TYPES", "application/pdf,application/octet-stream")
+	t.Setenv("REVIEWER_MEMCACHED_ENCRYPTION_KEYS_K2", "from-env")
+
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Server.Port != 9000 {
+		t.Errorf("server.port = %d, want 9000", cfg.Server.Port)
+	}
+	if cfg.Server.ReadTimeoutSec != 10 {
+		t.Errorf("server.read_timeout = %d, want 10 from the file", cfg.Server.ReadTimeoutSec)
+	}
+	if cfg.Metrics.ListenAddr != ":9090" {
+		t.Errorf("metrics.listen_addr = %q, want :9090", cfg.Metrics.ListenAddr)
+	}
+	if want := []string{"application/pdf", "application/octet-stream"}; !reflect.DeepEqual(cfg.Files.AllowedMIMETypes, want) {
+		t.Errorf("files.allowed_mime_types = %q, want %q", cfg.Files.AllowedMIMETypes, want)
+	}
+	if want := map[string]string{"k1": "from-file", "k2": "from-env"}; !reflect.DeepEqual(cfg.Memcached.Encryption.Keys, want) {
+		t.Errorf("memcached.encryption.keys = %v, want %v", cfg.Memcached.Encryption.Keys, want)
+	}
+}
+
+func TestLoadConfigPath(t *testing.T) {
+	t.Setenv(EnvConfigPath, writeConfig(t, testConfig))
+	cfg, err := Load("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Server.Port != 8080 {
+		t.Errorf("server.port = %d, want 8080 from %s", cfg.Server.Port, EnvConfigPath)
+	}
+
+	// Явный путь важнее переменной окружения
+	if _, err := Load(filepath.Join(t.TempDir(), "missing.yml")); err == nil {
+		t.Error("Load of a missing file succeeded")
+	}
+}
+
+func TestLoadRejectsInvalidOverride(t *testing.T) {
+	path := writeConfig(t, testConfig)
+	t.Setenv("REVIEWER_FILES_ALLOWED_MIME_TYPES", "application/pfd")
+	_, err := Load(path)
+	if err == nil || !strings.Contains(err.Error(), "files.allowed_mime_types[0]") {
+		t.Fatalf("err = %v, want an error for files.allowed_mime_types[0]", err)
+	}
+}
@@ -0,0 +1,203 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"regexp/syntax"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// originRegexPrefix совпадает с handler.OriginRegexPrefix
+const originRegexPrefix = "regex:"
+
+var (
+	logLevels  = map[string]bool{"debug": true, "info": true, "warn": true, "error": true}
+	logFormats = map[string]bool{"json": true, "text": true}
+
+	// apiPaths — маршруты API на основном порту (cmd/reviewer); путь метрик не должен с ними совпадать
+	apiPaths = []string{"/", "/ping", "/upload", "/status", "/get", "/export"}
+
+	hostLabel = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]*[a-z0-9])?$`)
+	labelPart = regexp.MustCompile(`^[a-z0-9-]*$`)
+
+	// Вторые уровни национальных зон, в которых регистрируют домены: co.uk, com.au, ...
+	secondLevelSuffixes = map[string]bool{"ac": true, "co": true, "com": true, "edu": true, "gov": true, "net": true, "org": true}
+
+	// uploadMediaTypes — типы, под которыми клиенты и http.DetectContentType отдают PDF
+	uploadMediaTypes = []string{"application/pdf", "application/x-pdf", "application/acrobat", "application/octet-stream"}
+)
+
+// Validate проверяет конфигурацию целиком и возвращает все найденные ошибки вместе
+func (c Config) Validate() error {
+	var errs []error
+	check := func(ok bool, key, format string, args ...any) {
+		if !ok {
+			errs = append(errs, fmt.Errorf("%s: "+format, append([]any{key}, args...)...))
+		}
+	}
+
+	s := c.Server
+	check(s.Port >= 1 && s.Port <= 65535, "server.port", "must be between 1 and 65535, got %d", s.Port)
+	check(s.ReadTimeoutSec > 0, "server.read_timeout", "must be positive, got %d", s.ReadTimeoutSec)
+	check(s.WriteTimeoutSec > 0, "server.write_timeout", "must be positive, got %d", s.WriteTimeoutSec)
+	check(s.ShutdownTimeoutSec > 0, "server.shutdown_timeout", "must be positive, got %d", s.ShutdownTimeoutSec)
+	check(s.LogLevel == "" || logLevels[s.LogLevel], "server.log_level", "unknown level %q", s.LogLevel)
+
+	// Куки и авторизация не должны уходить на любой сайт: с credentials нужны конкретные origins
+	cors := c.CORS
+	if cors.AllowCredentials {
+		check(len(cors.AllowedOrigins) > 0, "cors.allowed_origins", "must not be empty when allow_credentials is true")
+	}
+	errs = append(errs, checkOrigins("cors.allowed_origins", cors.AllowedOrigins, cors.AllowCredentials)...)
+	check(cors.MaxAgeSeconds >= 0, "cors.max_age_seconds", "must not be negative, got %d", cors.MaxAgeSeconds)
+	for i, route := range cors.Routes {
+		key := fmt.Sprintf("cors.routes[%d]", i)
+		check(strings.HasPrefix(route.Path, "/"), key+".path", "must start with \"/\", got %q", route.Path)
+		errs = append(errs, checkOrigins(key+".allowed_origins", route.AllowedOrigins, cors.AllowCredentials)...)
+	}
+
+	check(c.RateLimiter.RequestsPerMinute >= 0, "rate_limiter.requests_per_minute",
+		"must not be negative, got %d", c.RateLimiter.RequestsPerMinute)
+
+	m := c.Memcached
+	if m.Enable {
+		check(len(m.Servers) > 0, "memcached.servers", "must not be empty when memcached is enabled")
+		for i, server := range m.Servers {
+			check(strings.TrimSpace(server) != "", fmt.Sprintf("memcached.servers[%d]", i), "must not be empty")
+		}
+	}
+	check(m.DefaultTTL >= 0, "memcached.default_ttl", "must not be negative, got %d", m.DefaultTTL)
+	check(m.ChunkSize >= 0, "memcached.chunk_size", "must not be negative, got %d", m.ChunkSize)
+	check(m.ConnectAttempts >= 0, "memcached.connect_attempts", "must not be negative, got %d", m.ConnectAttempts)
+	check(m.ConnectBackoffSec >= 0, "memcached.connect_backoff", "must not be negative, got %d", m.ConnectBackoffSec)
+	check(m.HealthIntervalSec >= 0, "memcached.health_interval", "must not be negative, got %d", m.HealthIntervalSec)
+
+	f := c.Files
+	check(f.MaxFilesPerRequest >= 0, "files.max_files_per_request", "must not be negative, got %d", f.MaxFilesPerRequest)
+	check(f.MaxFileSize >= 0, "files.max_file_size", "must not be negative, got %d", f.MaxFileSize)
+	check(f.MaxProcessingTime > 0, "files.max_processing_time", "must be positive, got %d", f.MaxProcessingTime)
+	check(f.Workers >= 0, "files.workers", "must not be negative, got %d", f.Workers)
+	check(f.QueueSize >= 0, "files.queue_size", "must not be negative, got %d", f.QueueSize)
+	for i, mimeType := range f.AllowedMIMETypes {
+		check(slices.Contains(uploadMediaTypes, mimeType), fmt.Sprintf("files.allowed_mime_types[%d]", i),
+			"unsupported MIME type %q, want one of %s", mimeType, strings.Join(uploadMediaTypes, ", "))
+	}
+
+	if mp := c.Metrics.Path; c.Metrics.Enabled && mp != "" {
+		check(strings.HasPrefix(mp, "/") && !strings.ContainsAny(mp, " \t{}"), "metrics.path",
+			"must be a plain path starting with \"/\", got %q", mp)
+		// На отдельном адресе метрики ни с чем не пересекаются
+		check(c.Metrics.ListenAddr != "" || !slices.Contains(apiPaths, mp), "metrics.path",
+			"%q is already served by the API", mp)
+	}
+	check(c.Metrics.MaxIPs >= 0, "metrics.max_ips", "must not be negative, got %d", c.Metrics.MaxIPs)
+	check(c.Metrics.CollectIntervalSec >= 0, "metrics.collect_interval",
+		"must not be negative, got %d", c.Metrics.CollectIntervalSec)
+
+	// Пустые уровень и формат логгер заменяет значениями по умолчанию
+	check(c.Logging.Level == "" || logLevels[c.Logging.Level], "logging.level", "unknown level %q", c.Logging.Level)
+	check(c.Logging.Format == "" || logFormats[c.Logging.Format], "logging.format", "unknown format %q", c.Logging.Format)
+
+	return errors.Join(errs...)
+}
+
+// checkOrigins проверяет origin-шаблоны: не больше одной «*», корректные regex:, а с credentials —
+// отсутствие "*" и шаблонов, которые не привязаны к схеме и одному регистрируемому домену
+func checkOrigins(key string, origins []string, credentials bool) []error {
+	var errs []error
+	for i, origin := range origins {
+		if expr, ok := strings.CutPrefix(origin, originRegexPrefix); ok {
+			re, err := syntax.Parse(expr, syntax.Perl)
+			switch {
+			case err != nil:
+				errs = append(errs, fmt.Errorf("%s[%d]: invalid regex: %w", key, i, err))
+			case credentials && !anchoredOrigin(literalAffixes(re.Simplify())):
+				errs = append(errs, fmt.Errorf("%s[%d]: regex %q must start with a literal scheme and end with a literal "+
+					"registrable domain when allow_credentials is true", key, i, expr))
+			}
+			continue
+		}
+		prefix, suffix, wildcard := strings.Cut(strings.ToLower(origin), "*")
+		switch {
+		case origin == "*" && credentials:
+			errs = append(errs, fmt.Errorf("%s[%d]: \"*\" is not allowed when allow_credentials is true", key, i))
+		case strings.Contains(suffix, "*"):
+			errs = append(errs, fmt.Errorf("%s[%d]: only one \"*\" is allowed, got %q", key, i, origin))
+		case wildcard && credentials && !anchoredOrigin(prefix, suffix):
+			errs = append(errs, fmt.Errorf("%s[%d]: pattern %q must look like \"https://*.example.com\" "+
+				"when allow_credentials is true", key, i, origin))
+		}
+	}
+	return errs
+}
+
+// literalAffixes возвращает неизменяемые начало и конец выражения: то, чем совпавшая строка
+// обязательно начинается и заканчивается
+func literalAffixes(re *syntax.Regexp) (prefix, suffix string) {
+	for re.Op == syntax.OpCapture {
+		re = re.Sub[0]
+	}
+	switch re.Op {
+	case syntax.OpLiteral:
+		return string(re.Rune), string(re.Rune)
+	case syntax.OpConcat:
+		if first := re.Sub[0]; first.Op == syntax.OpLiteral {
+			prefix = string(first.Rune)
+		}
+		if last := re.Sub[len(re.Sub)-1]; last.Op == syntax.OpLiteral {
+			suffix = string(last.Rune)
+		}
+	}
+	return prefix, suffix
+}
+
+// anchoredOrigin сообщает, что все origins с таким неизменяемым началом и концом имеют одну схему
+// и лежат внутри одного регистрируемого домена: "https://" … ".example.com[:порт]"
+func anchoredOrigin(prefix, suffix string) bool {
+	prefix, suffix = strings.ToLower(prefix), strings.ToLower(suffix)
+	if !strings.HasPrefix(prefix, "https://") && !strings.HasPrefix(prefix, "http://") {
+		return false
+	}
+	// Конец, начинающийся со схемы, — origin целиком; иначе домен начинается после первой точки,
+	// а перед ней может стоять только часть метки: "-preview.example.com"
+	var host string
+	if _, rest, ok := strings.Cut(suffix, "://"); ok {
+		host = rest
+	} else {
+		label, rest, ok := strings.Cut(suffix, ".")
+		if !ok || !labelPart.MatchString(label) {
+			return false
+		}
+		host = rest
+	}
+	if h, port, ok := strings.Cut(host, ":"); ok {
+		if _, err := strconv.ParseUint(port, 10, 16); err != nil {
+			return false
+		}
+		host = h
+	}
+	return registrable(host)
+}
+
+// registrable грубо отличает регистрируемый домен от публичного суффикса без списка PSL:
+// нужны хотя бы две метки, а у двухбуквенных национальных зон — три, если вторая из типовых
+// ("example.co.uk", но не "co.uk")
+func registrable(host string) bool {
+	labels := strings.Split(host, ".")
+	for _, label := range labels {
+		if !hostLabel.MatchString(label) {
+			return false
+		}
+	}
+	n := len(labels)
+	if n < 2 {
+		return false
+	}
+	if n == 2 && len(labels[1]) == 2 && secondLevelSuffixes[labels[0]] {
+		return false
+	}
+	return true
+}
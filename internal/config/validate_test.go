@@ -0,0 +1,150 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCheckOrigins(t *testing.T) {
+	tests := []struct {
+		origin      string
+		credentials bool
+		wantErr     bool
+	}{
+		{origin: "https://app.example.com", credentials: true},
+		{origin: "https://*.preview.example.com", credentials: true},
+		{origin: "https://*.example.com:8443", credentials: true},
+		{origin: "https://pr-*.example.co.uk", credentials: true},
+		{origin: `regex:https://pr-\d+\.review\.example\.com`, credentials: true},
+		{origin: `regex:https://[a-z]+\.example\.com:8080`, credentials: true},
+		{origin: `regex:(https://app\.example\.com)`, credentials: true},
+		{origin: `regex:https?://.*\.example\.com`, credentials: true, wantErr: true},
+		{origin: "*", credentials: true, wantErr: true},
+		{origin: "https://*", credentials: true, wantErr: true},
+		{origin: "*.example.com", credentials: true, wantErr: true},
+		{origin: "https://*.com", credentials: true, wantErr: true},
+		{origin: "https://*.co.uk", credentials: true, wantErr: true},
+		{origin: "https://*example.com", credentials: true, wantErr: true},
+		{origin: "https://app.example.*", credentials: true, wantErr: true},
+		{origin: "https://*.example.com/*", credentials: true, wantErr: true},
+		{origin: "regex:.*", credentials: true, wantErr: true},
+		{origin: `regex:https://.*`, credentials: true, wantErr: true},
+		{origin: `regex:https://.*example\.com`, credentials: true, wantErr: true},
+		{origin: `regex:https://.*\.example\.com.*`, credentials: true, wantErr: true},
+		{origin: `regex:https://a\.example\.com|https://.*`, credentials: true, wantErr: true},
+		{origin: `regex:.*\.example\.com`, credentials: true, wantErr: true},
+		// Без credentials широкие шаблоны допустимы: ответы не несут кук и авторизации
+		{origin: "*"},
+		{origin: "https://*"},
+		{origin: "regex:.*"},
+		{origin: "https://*.*.example.com", wantErr: true},
+		{origin: "regex:(", wantErr: true},
+	}
+	for _, tt := range tests {
+		errs := checkOrigins("cors.allowed_origins", []string{tt.origin}, tt.credentials)
+		if (len(errs) > 0) != tt.wantErr {
+			t.Errorf("checkOrigins(%q, credentials=%v) = %v, wantErr %v", tt.origin, tt.credentials, errs, tt.wantErr)
+		}
+	}
+}
+
+func TestValidateMetricsPath(t *testing.T) {
+	tests := []struct {
+		path       string
+		listenAddr string
+		wantErr    bool
+	}{
+		{path: ""},
+		{path: "/metrics"},
+		{path: "/internal/metrics"},
+		{path: "metrics", wantErr: true},
+		{path: "/metrics {x}", wantErr: true},
+		{path: "/{path}", wantErr: true},
+		{path: "/status", wantErr: true},
+		{path: "/upload", wantErr: true},
+		{path: "/get", wantErr: true},
+		{path: "/export", wantErr: true},
+		{path: "/", wantErr: true},
+		{path: "/status", listenAddr: ":9090"},
+		{path: "metrics", listenAddr: ":9090", wantErr: true},
+	}
+	for _, tt := range tests {
+		cfg := validConfig()
+		cfg.Metrics = Metrics{Enabled: true, Path: tt.path, ListenAddr: tt.listenAddr}
+		if err := cfg.Validate(); (err != nil) != tt.wantErr {
+			t.Errorf("metrics.path %q, listen_addr %q: err = %v, wantErr %v", tt.path, tt.listenAddr, err, tt.wantErr)
+		}
+	}
+}
+
+// validConfig — минимальная конфигурация, которую Validate принимает
+func validConfig() Config {
+	return Config{
+		Server: Server{Port: 8080, ReadTimeoutSec: 1, WriteTimeoutSec: 1, ShutdownTimeoutSec: 1},
+		Files:  Files{MaxProcessingTime: 1},
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		modify  func(c *Config)
+		wantErr string
+	}{
+		{name: "valid", modify: func(c *Config) {}},
+		{name: "port", modify: func(c *Config) { c.Server.Port = 70000 }, wantErr: "server.port"},
+		{name: "read timeout", modify: func(c *Config) { c.Server.ReadTimeoutSec = 0 }, wantErr: "server.read_timeout"},
+		{name: "log level", modify: func(c *Config) { c.Server.LogLevel = "verbose" }, wantErr: "server.log_level"},
+		{name: "credentials without origins", modify: func(c *Config) { c.CORS.AllowCredentials = true }, wantErr: "cors.allowed_origins"},
+		{name: "route path", modify: func(c *Config) { c.CORS.Routes = []CORSRoute{{Path: "admin"}} }, wantErr: "cors.routes[0].path"},
+		{name: "negative max age", modify: func(c *Config) { c.CORS.MaxAgeSeconds = -1 }, wantErr: "cors.max_age_seconds"},
+		{name: "memcached without servers", modify: func(c *Config) { c.Memcached.Enable = true }, wantErr: "memcached.servers"},
+		{name: "blank memcached server", modify: func(c *Config) {
+			c.Memcached = Memcached{Enable: true, Servers: []string{" "}}
+		}, wantErr: "memcached.servers[0]"},
+		{name: "negative ttl", modify: func(c *Config) { c.Memcached.DefaultTTL = -1 }, wantErr: "memcached.default_ttl"},
+		{name: "processing time", modify: func(c *Config) { c.Files.MaxProcessingTime = 0 }, wantErr: "files.max_processing_time"},
+		{name: "pdf types", modify: func(c *Config) {
+			c.Files.AllowedMIMETypes = []string{"application/pdf", "application/octet-stream"}
+		}},
+		{name: "mistyped pdf", modify: func(c *Config) {
+			c.Files.AllowedMIMETypes = []string{"application/pfd"}
+		}, wantErr: "files.allowed_mime_types[0]"},
+		{name: "unrelated type", modify: func(c *Config) {
+			c.Files.AllowedMIMETypes = []string{"application/pdf", "text/plain"}
+		}, wantErr: "files.allowed_mime_types[1]"},
+		{name: "type with parameters", modify: func(c *Config) {
+			c.Files.AllowedMIMETypes = []string{"application/pdf; charset=binary"}
+		}, wantErr: "files.allowed_mime_types[0]"},
+		{name: "max ips", modify: func(c *Config) { c.Metrics.MaxIPs = -1 }, wantErr: "metrics.max_ips"},
+		{name: "logging format", modify: func(c *Config) { c.Logging.Format = "xml" }, wantErr: "logging.format"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := validConfig()
+			tt.modify(&cfg)
+			err := cfg.Validate()
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Fatalf("unexpected error: %v", err)
+			case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr+":")):
+				t.Fatalf("err = %v, want an error for %s", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateReportsAllErrors(t *testing.T) {
+	cfg := validConfig()
+	cfg.Server.Port = 0
+	cfg.Logging.Level = "loud"
+	err := cfg.Validate()
+	if err == nil {
+		t.Fatal("no error")
+	}
+	for _, key := range []string{"server.port", "logging.level"} {
+		if !strings.Contains(err.Error(), key) {
+			t.Errorf("error %q does not mention %s", err, key)
+		}
+	}
+}
@@ -0,0 +1,131 @@
+package config
+
+import (
+	"fmt"
+	"log/slog"
+	"reflect"
+	"sync"
+	"sync/atomic"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/spf13/viper"
+)
+
+// Watcher перечитывает файл конфигурации при изменении и публикует новый снимок подписчикам.
+// Снимок отражает действующую конфигурацию: изменения, требующие перезапуска, в него не попадают,
+// а только пишутся в лог.
+type Watcher struct {
+	v       *viper.Viper
+	current atomic.Pointer[Config]
+
+	// mu упорядочивает перечитывания и вызовы подписчиков
+	mu          sync.Mutex
+	subscribers []func(Config)
+}
+
+// NewWatcher загружает конфигурацию так же, как Load; слежение включает Start
+func NewWatcher(path string) (*Watcher, error) {
+	v, err := newViper(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := v.ReadInConfig(); err != nil {
+		return nil, fmt.Errorf("read config: %w", err)
+	}
+	cfg, err := decode(v)
+	if err != nil {
+		return nil, err
+	}
+	w := &Watcher{v: v}
+	w.current.Store(&cfg)
+	return w, nil
+}
+
+// Current возвращает действующий снимок конфигурации
+func (w *Watcher) Current() Config {
+	return *w.current.Load()
+}
+
+// Subscribe регистрирует fn, которая получает каждый новый снимок после успешного перечитывания
+func (w *Watcher) Subscribe(fn func(Config)) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.subscribers = append(w.subscribers, fn)
+}
+
+// Start начинает следить за файлом конфигурации
+func (w *Watcher) Start() {
+	w.v.OnConfigChange(func(fsnotify.Event) { w.reload() })
+	w.v.WatchConfig()
+}
+
+// reload перечитывает файл; при ошибке чтения или проверки остаётся прежний снимок
+func (w *Watcher) reload() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if err := w.v.ReadInConfig(); err != nil {
+		slog.Error("config reload failed, keeping current config", "err", err)
+		return
+	}
+	next, err := decode(w.v)
+	if err != nil {
+		slog.Error("config reload failed, keeping current config", "err", err)
+		return
+	}
+
+	prev := w.Current()
+	effective := applyReloadable(prev, next)
+	for _, key := range changedKeys(effective, next) {
+		slog.Warn("config change requires restart", "key", key)
+	}
+	applied := changedKeys(prev, effective)
+	if len(applied) == 0 {
+		return
+	}
+
+	w.current.Store(&effective)
+	for _, fn := range w.subscribers {
+		fn(effective)
+	}
+	slog.Info("config reloaded", "keys", applied)
+}
+
+// applyReloadable переносит в cur поля, которые применяются без перезапуска.
+// Список должен совпадать с тем, что обновляют подписчики в main.
+func applyReloadable(cur, next Config) Config {
+	cur.Logging.Level = next.Logging.Level
+	cur.CORS = next.CORS
+	cur.RateLimiter.Enabled = next.RateLimiter.Enabled
+	cur.RateLimiter.RequestsPerMinute = next.RateLimiter.RequestsPerMinute
+	cur.Files.MaxFilesPerRequest = next.Files.MaxFilesPerRequest
+	cur.Files.MaxFileSize = next.Files.MaxFileSize
+	cur.Files.AllowedMIMETypes = next.Files.AllowedMIMETypes
+	return cur
+}
+
+// changedKeys возвращает ключи конфигурации (в виде server.port), значения которых различаются
+func changedKeys(a, b Config) []string {
+	var keys []string
+	diff(reflect.ValueOf(a), reflect.ValueOf(b), "", &keys)
+	return keys
+}
+
+func diff(a, b reflect.Value, prefix string, keys *[]string) {
+	t := a.Type()
+	for i := range t.NumField() {
+		name := t.Field(i).Tag.Get("mapstructure")
+		if name == "" {
+			continue
+		}
+		key := prefix + name
+		fa, fb := a.Field(i), b.Field(i)
+		if fa.Kind() == reflect.Struct {
+			diff(fa, fb, key+".", keys)
+			continue
+		}
+		if !reflect.DeepEqual(fa.Interface(), fb.Interface()) {
+			*keys = append(*keys, key)
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package config
+
+import (
+	"os"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestWatcherReload(t *testing.T) {
+	path := writeConfig(t, testConfig+"rate_limiter:\n  enabled: true\n  requests_per_minute: 60\n")
+	w, err := NewWatcher(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var published []Config
+	w.Subscribe(func(cfg Config) { published = append(published, cfg) })
+
+	rewrite := func(old, new string) {
+		t.Helper()
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(strings.Replace(string(data), old, new, 1)), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		w.reload()
+	}
+
+	// Лимит применяется сразу, порт — только после перезапуска
+	rewrite("requests_per_minute: 60", "requests_per_minute: 10")
+	rewrite("port: 8080", "port: 9000")
+	if len(published) != 1 {
+		t.Fatalf("published %d snapshots, want 1", len(published))
+	}
+	cfg := w.Current()
+	if cfg.RateLimiter.RequestsPerMinute != 10 || cfg.Server.Port != 8080 {
+		t.Errorf("current: requests_per_minute = %d, port = %d; want 10, 8080",
+			cfg.RateLimiter.RequestsPerMinute, cfg.Server.Port)
+	}
+	if keys := changedKeys(published[0], cfg); len(keys) != 0 {
+		t.Errorf("published snapshot differs from current in %v", keys)
+	}
+
+	// Ошибочная конфигурация не заменяет действующую
+	rewrite("requests_per_minute: 10", "requests_per_minute: -1")
+	if len(published) != 1 || w.Current().RateLimiter.RequestsPerMinute != 10 {
+		t.Errorf("invalid config applied: %d snapshots, requests_per_minute = %d",
+			len(published), w.Current().RateLimiter.RequestsPerMinute)
+	}
+}
+
+func TestChangedKeys(t *testing.T) {
+	a := Config{}
+	b := a
+	b.Server.Port = 1
+	b.CORS.AllowedOrigins = []string{"https://example.com"}
+	b.Files.MaxFileSize = 1
+	want := []string{"server.port", "cors.allowed_origins", "files.max_file_size"}
+	if got := changedKeys(a, b); !slices.Equal(got, want) {
+		t.Errorf("changedKeys = %q, want %q", got, want)
+	}
+}
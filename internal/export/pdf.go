@@ -0,0 +1,518 @@
+package export
+
+import (
+	"context"
+	_ "embed"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/Caritas-Team/reviewer/internal/storage"
+	"github.com/Caritas-Team/reviewer/internal/usecase/file"
+	"github.com/Caritas-Team/reviewer/internal/usecase/report"
+	"github.com/go-pdf/fpdf"
+)
+
+// Шрифты DejaVu встраиваются в каждый отчёт: базовые шрифты PDF не содержат кириллицы
+var (
+	//go:embed fonts/DejaVuSans.ttf
+	regularFont []byte
+	//go:embed fonts/DejaVuSans-Bold.ttf
+	boldFont []byte
+)
+
+const (
+	fontFamily = "DejaVu"
+	dateLayout = "02.01.2006"
+
+	margin     = 15.0
+	lineHeight = 7.0
+	rowHeight  = 7.0
+	nameWidth  = 60.0
+	normWidth  = 25.0
+	deltaWidth = 25.0
+
+	chartHeight = 65.0
+	chartGap    = 10.0
+)
+
+type rgb struct{ r, g, b int }
+
+var (
+	// palette — цвета серий по датам заключений
+	palette = []rgb{
+		{52, 101, 164}, {245, 121, 0}, {78, 154, 6}, {204, 0, 0},
+		{117, 80, 123}, {193, 125, 17}, {85, 87, 83},
+	}
+	gridColor   = rgb{211, 215, 207}
+	normColor   = rgb{214, 240, 206}
+	headerColor = rgb{238, 238, 236}
+)
+
+type labels struct {
+	title, child, period, reports, specialists, generated, conclusions string
+	indicators, indicator, norm, change, charts, overview              string
+}
+
+var texts = map[report.Language]labels{
+	report.LanguageRU: {
+		title:       "Отчёт о результатах диагностики",
+		child:       "Ребёнок",
+		period:      "Период",
+		reports:     "Заключений",
+		specialists: "Специалисты",
+		generated:   "Сформирован",
+		conclusions: "Выводы",
+		indicators:  "Показатели",
+		indicator:   "Показатель",
+		norm:        "Норма",
+		change:      "Изм., %",
+		charts:      "Динамика показателей",
+		overview:    "Баллы по показателям",
+	},
+	report.LanguageEN: {
+		title:       "Diagnostic results report",
+		child:       "Child",
+		period:      "Period",
+		reports:     "Reports",
+		specialists: "Specialists",
+		generated:   "Generated",
+		conclusions: "Conclusions",
+		indicators:  "Indicators",
+		indicator:   "Indicator",
+		norm:        "Norm",
+		change:      "Chg, %",
+		charts:      "Indicator dynamics",
+		overview:    "Scores by indicator",
+	},
+}
+
+// RenderResult — этап обработки: строит PDF-отчёт по извлечённым данным операции
+// и делает его результатом, который GET /status отдаёт для Accept: application/pdf
+func RenderResult(ctx context.Context, op storage.Operation) (storage.Operation, error) {
+	if err := ctx.Err(); err != nil {
+		return op, err
+	}
+	rep, err := file.ReadReport(op.ReportPath)
+	if err != nil {
+		return op, err
+	}
+
+	path := strings.TrimSuffix(op.FilePath, filepath.Ext(op.FilePath)) + ".report.pdf"
+	if err := renderFile(path, []file.DiagnosticReport{rep}); err != nil {
+		return op, err
+	}
+	op.ResultPath = path
+	return op, nil
+}
+
+// renderFile сохраняет отчёт в файл. Метрики экспорта относятся к запросам GET /export,
+// фоновая отрисовка учитывается во времени и исходе обработки файла.
+func renderFile(path string, reports []file.DiagnosticReport) error {
+	f, err := os.OpenFile(filepath.Clean(path), os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("create report: %w", err)
+	}
+	if err := RenderPDF(f, reports, nil, report.LanguageRU); err != nil {
+		_ = f.Close()
+		_ = os.Remove(path)
+		return err
+	}
+	return f.Close()
+}
+
+// RenderPDF выводит отчёт: титульная страница с выводами, таблица показателей и графики.
+// cmp может быть nil, если заключение одно.
+func RenderPDF(w io.Writer, reports []file.DiagnosticReport, cmp *report.Comparison, lang report.Language) error {
+	t, ok := texts[lang]
+	if !ok {
+		t = texts[report.LanguageRU]
+	}
+	table := report.BuildTable(reports, cmp)
+
+	pdf := fpdf.New("P", "mm", "A4", "")
+	pdf.SetMargins(margin, margin, margin)
+	pdf.SetAutoPageBreak(true, margin)
+	pdf.AddUTF8FontFromBytes(fontFamily, "", regularFont)
+	pdf.AddUTF8FontFromBytes(fontFamily, "B", boldFont)
+	pdf.SetTitle(t.title, true)
+	pdf.SetFooterFunc(func() {
+		pdf.SetY(-margin + 5)
+		pdf.SetFont(fontFamily, "", 8)
+		pdf.CellFormat(0, 5, fmt.Sprintf("%d", pdf.PageNo()), "", 0, "C", false, 0, "")
+	})
+
+	r := renderer{pdf: pdf, t: t, lang: lang}
+	r.titlePage(reports, table, cmp)
+	r.tablePage(table, cmp != nil)
+	r.chartsPage(table)
+
+	if err := pdf.Error(); err != nil {
+		return fmt.Errorf("render pdf: %w", err)
+	}
+	return pdf.Output(w)
+}
+
+type renderer struct {
+	pdf  *fpdf.Fpdf
+	t    labels
+	lang report.Language
+}
+
+func (r *renderer) contentWidth() float64 {
+	w, _ := r.pdf.GetPageSize()
+	return w - 2*margin
+}
+
+func (r *renderer) heading(text string) {
+	r.pdf.SetFont(fontFamily, "B", 14)
+	r.pdf.CellFormat(0, 10, text, "", 1, "L", false, 0, "")
+	r.pdf.Ln(2)
+}
+
+func (r *renderer) titlePage(reports []file.DiagnosticReport, table report.Table, cmp *report.Comparison) {
+	pdf := r.pdf
+	pdf.AddPage()
+	pdf.Ln(20)
+	pdf.SetFont(fontFamily, "B", 20)
+	pdf.MultiCell(0, 10, r.t.title, "", "C", false)
+	pdf.Ln(10)
+
+	var child string
+	specialists := make([]string, 0, len(reports))
+	for _, rep := range reports {
+		if child == "" {
+			child = rep.ChildID
+		}
+		if rep.Specialist != "" && !slices.Contains(specialists, rep.Specialist) {
+			specialists = append(specialists, rep.Specialist)
+		}
+	}
+
+	info := [][2]string{{r.t.child, child}}
+	if len(table.Dates) > 0 {
+		period := formatDate(table.Dates[0])
+		if last := table.Dates[len(table.Dates)-1]; !last.Equal(table.Dates[0]) {
+			period += " — " + formatDate(last)
+		}
+		info = append(info, [2]string{r.t.period, period})
+	}
+	info = append(info, [2]string{r.t.reports, fmt.Sprintf("%d", len(reports))})
+	if len(specialists) > 0 {
+		info = append(info, [2]string{r.t.specialists, strings.Join(specialists, ", ")})
+	}
+	info = append(info, [2]string{r.t.generated, formatDate(time.Now())})
+
+	for _, row := range info {
+		pdf.SetFont(fontFamily, "B", 11)
+		pdf.CellFormat(45, lineHeight, row[0]+":", "", 0, "L", false, 0, "")
+		pdf.SetFont(fontFamily, "", 11)
+		pdf.MultiCell(0, lineHeight, row[1], "", "L", false)
+	}
+
+	if cmp == nil || len(cmp.Conclusions) == 0 {
+		return
+	}
+	pdf.Ln(8)
+	r.heading(r.t.conclusions)
+	pdf.SetFont(fontFamily, "", 11)
+	for _, c := range cmp.Conclusions {
+		pdf.MultiCell(0, lineHeight, "•  "+c, "", "L", false)
+	}
+}
+
+func (r *renderer) tablePage(table report.Table, withDelta bool) {
+	pdf := r.pdf
+	pdf.AddPage()
+	r.heading(r.t.indicators)
+
+	free := r.contentWidth() - nameWidth - normWidth
+	if withDelta {
+		free -= deltaWidth
+	}
+	dateWidth := free / float64(max(len(table.Dates), 1))
+	fontSize := 10.0
+	if dateWidth < 18 {
+		fontSize = 8
+	}
+
+	header := func() {
+		pdf.SetFont(fontFamily, "B", fontSize)
+		pdf.SetFillColor(headerColor.r, headerColor.g, headerColor.b)
+		pdf.CellFormat(nameWidth, rowHeight, r.t.indicator, "1", 0, "L", true, 0, "")
+		pdf.CellFormat(normWidth, rowHeight, r.t.norm, "1", 0, "C", true, 0, "")
+		for _, d := range table.Dates {
+			pdf.CellFormat(dateWidth, rowHeight, formatDate(d), "1", 0, "C", true, 0, "")
+		}
+		if withDelta {
+			pdf.CellFormat(deltaWidth, rowHeight, r.t.change, "1", 0, "C", true, 0, "")
+		}
+		pdf.Ln(-1)
+	}
+	header()
+
+	_, pageHeight := pdf.GetPageSize()
+	pdf.SetFont(fontFamily, "", fontSize)
+	for _, row := range table.Rows {
+		if pdf.GetY()+rowHeight > pageHeight-margin {
+			pdf.AddPage()
+			header()
+			pdf.SetFont(fontFamily, "", fontSize)
+		}
+		pdf.CellFormat(nameWidth, rowHeight, r.fit(row.Name, nameWidth-2), "1", 0, "L", false, 0, "")
+		pdf.CellFormat(normWidth, rowHeight, r.fit(row.Norm, normWidth-2), "1", 0, "C", false, 0, "")
+		for _, score := range row.Scores {
+			text := ""
+			if score != nil {
+				text = r.number(*score)
+			}
+			pdf.CellFormat(dateWidth, rowHeight, text, "1", 0, "C", false, 0, "")
+		}
+		if withDelta {
+			text := ""
+			if row.Total != nil && row.Total.Percent != nil {
+				text = r.number(math.Round(*row.Total.Percent*10) / 10)
+				if *row.Total.Percent > 0 {
+					text = "+" + text
+				}
+			}
+			pdf.CellFormat(deltaWidth, rowHeight, text, "1", 0, "C", false, 0, "")
+		}
+		pdf.Ln(-1)
+	}
+}
+
+func (r *renderer) chartsPage(table report.Table) {
+	if len(table.Rows) == 0 {
+		return
+	}
+	pdf := r.pdf
+	pdf.AddPage()
+	r.heading(r.t.charts)
+
+	width := r.contentWidth()
+	y := pdf.GetY()
+	r.barChart(margin, y, width, chartHeight+10, table)
+	y += chartHeight + 10 + chartGap
+
+	if len(table.Dates) < 2 {
+		return
+	}
+
+	// Линейные графики по показателям — по два в ряд
+	_, pageHeight := pdf.GetPageSize()
+	half := (width - chartGap) / 2
+	for i, row := range table.Rows {
+		if i%2 == 0 && y+chartHeight > pageHeight-margin {
+			pdf.AddPage()
+			y = margin
+		}
+		x := margin
+		if i%2 == 1 {
+			x += half + chartGap
+		}
+		r.lineChart(x, y, half, chartHeight, row, table.Dates)
+		if i%2 == 1 {
+			y += chartHeight + chartGap
+		}
+	}
+}
+
+// barChart — сгруппированные столбцы: по показателю на группу, по дате на столбец
+func (r *renderer) barChart(x, y, w, h float64, table report.Table) {
+	pdf := r.pdf
+	pdf.SetFont(fontFamily, "B", 10)
+	pdf.Text(x, y+4, r.t.overview)
+
+	top := 0.0
+	for _, row := range table.Rows {
+		for _, s := range row.Scores {
+			if s != nil {
+				top = math.Max(top, *s)
+			}
+		}
+	}
+	top = niceCeil(top)
+
+	const labelArea = 22.0
+	legendY := y + 8
+	plotX, plotY := x+10, legendY+6
+	plotW, plotH := w-10, h-labelArea-(plotY-y)
+	r.axes(plotX, plotY, plotW, plotH, 0, top)
+
+	for i, d := range table.Dates {
+		c := palette[i%len(palette)]
+		pdf.SetFillColor(c.r, c.g, c.b)
+		lx := x + 10 + float64(i)*28
+		pdf.Rect(lx, legendY-2.5, 3, 3, "F")
+		pdf.SetFont(fontFamily, "", 8)
+		pdf.Text(lx+4, legendY, formatDate(d))
+	}
+
+	group := plotW / float64(len(table.Rows))
+	bar := group * 0.8 / float64(max(len(table.Dates), 1))
+	for i, row := range table.Rows {
+		gx := plotX + float64(i)*group + group*0.1
+		for j, s := range row.Scores {
+			if s == nil || top == 0 {
+				continue
+			}
+			c := palette[j%len(palette)]
+			pdf.SetFillColor(c.r, c.g, c.b)
+			bh := *s / top * plotH
+			pdf.Rect(gx+float64(j)*bar, plotY+plotH-bh, bar, bh, "F")
+		}
+
+		// Подписи повёрнуты, чтобы длинные названия не накладывались друг на друга
+		pdf.SetFont(fontFamily, "", 7)
+		lx, ly := gx+group*0.4, plotY+plotH+3
+		pdf.TransformBegin()
+		pdf.TransformRotate(35, lx, ly)
+		label := r.fit(row.Name, labelArea*1.4)
+		pdf.Text(lx-pdf.GetStringWidth(label), ly, label)
+		pdf.TransformEnd()
+	}
+}
+
+// lineChart — баллы одного показателя по датам с полосой нормы
+func (r *renderer) lineChart(x, y, w, h float64, row report.TableRow, dates []time.Time) {
+	pdf := r.pdf
+	pdf.SetFont(fontFamily, "B", 9)
+	pdf.Text(x, y+4, r.fit(row.Name, w))
+
+	lo, hi, hasNorm := file.Indicator{Norm: row.Norm}.NormRange()
+	bottom, top := math.Inf(1), math.Inf(-1)
+	for _, s := range row.Scores {
+		if s != nil {
+			bottom, top = math.Min(bottom, *s), math.Max(top, *s)
+		}
+	}
+	if math.IsInf(top, -1) {
+		return
+	}
+	if hasNorm {
+		if !math.IsInf(lo, 0) {
+			bottom, top = math.Min(bottom, lo), math.Max(top, lo)
+		}
+		if !math.IsInf(hi, 0) {
+			bottom, top = math.Min(bottom, hi), math.Max(top, hi)
+		}
+	}
+	bottom = math.Min(0, bottom)
+	top = niceCeil(top)
+	if top <= bottom {
+		top = bottom + 1
+	}
+
+	plotX, plotY := x+10, y+8
+	plotW, plotH := w-12, h-16
+	scaleY := func(v float64) float64 {
+		v = math.Max(bottom, math.Min(top, v))
+		return plotY + plotH - (v-bottom)/(top-bottom)*plotH
+	}
+
+	if hasNorm {
+		y1, y2 := scaleY(hi), scaleY(lo)
+		pdf.SetFillColor(normColor.r, normColor.g, normColor.b)
+		pdf.Rect(plotX, y1, plotW, y2-y1, "F")
+	}
+	r.axes(plotX, plotY, plotW, plotH, bottom, top)
+
+	step := plotW / float64(max(len(dates)-1, 1))
+	pointX := func(i int) float64 {
+		if len(dates) == 1 {
+			return plotX + plotW/2
+		}
+		return plotX + float64(i)*step
+	}
+
+	c := palette[0]
+	pdf.SetDrawColor(c.r, c.g, c.b)
+	pdf.SetFillColor(c.r, c.g, c.b)
+	pdf.SetLineWidth(0.6)
+	prev := -1
+	for i, s := range row.Scores {
+		if s == nil {
+			continue
+		}
+		if prev >= 0 {
+			pdf.Line(pointX(prev), scaleY(*row.Scores[prev]), pointX(i), scaleY(*s))
+		}
+		pdf.Circle(pointX(i), scaleY(*s), 0.9, "F")
+		prev = i
+	}
+	pdf.SetLineWidth(0.2)
+	pdf.SetDrawColor(0, 0, 0)
+
+	pdf.SetFont(fontFamily, "", 6)
+	for i, d := range dates {
+		label := d.Format("02.01.06")
+		pdf.Text(pointX(i)-pdf.GetStringWidth(label)/2, plotY+plotH+4, label)
+	}
+}
+
+// axes рисует оси, горизонтальную сетку и подписи значений
+func (r *renderer) axes(x, y, w, h, bottom, top float64) {
+	pdf := r.pdf
+	const ticks = 4
+	pdf.SetFont(fontFamily, "", 6)
+	pdf.SetLineWidth(0.1)
+	for i := 0; i <= ticks; i++ {
+		v := bottom + (top-bottom)*float64(i)/ticks
+		ty := y + h - h*float64(i)/ticks
+		pdf.SetDrawColor(gridColor.r, gridColor.g, gridColor.b)
+		pdf.Line(x, ty, x+w, ty)
+		label := r.number(v)
+		pdf.Text(x-1-pdf.GetStringWidth(label), ty+1, label)
+	}
+	pdf.SetDrawColor(0, 0, 0)
+	pdf.SetLineWidth(0.2)
+	pdf.Line(x, y, x, y+h)
+	pdf.Line(x, y+h, x+w, y+h)
+}
+
+// fit обрезает текст с многоточием, чтобы он поместился в ширину текущим шрифтом
+func (r *renderer) fit(text string, width float64) string {
+	if r.pdf.GetStringWidth(text) <= width {
+		return text
+	}
+	runes := []rune(text)
+	for len(runes) > 0 && r.pdf.GetStringWidth(string(runes)+"…") > width {
+		runes = runes[:len(runes)-1]
+	}
+	return string(runes) + "…"
+}
+
+func (r *renderer) number(v float64) string {
+	decimal := byte(',')
+	if r.lang == report.LanguageEN {
+		decimal = '.'
+	}
+	return report.FormatNumber(v, decimal)
+}
+
+// niceCeil округляет верхнюю границу шкалы до 1, 2, 5 × 10^n
+func niceCeil(v float64) float64 {
+	if v <= 0 {
+		return 1
+	}
+	exp := math.Pow(10, math.Floor(math.Log10(v)))
+	for _, m := range []float64{1, 2, 5, 10} {
+		if v <= m*exp {
+			return m * exp
+		}
+	}
+	return 10 * exp
+}
+
+func formatDate(t time.Time) string {
+	if t.IsZero() {
+		return "—"
+	}
+	return t.Format(dateLayout)
+}
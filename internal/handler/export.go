@@ -0,0 +1,125 @@
+package handler
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Caritas-Team/reviewer/internal/export"
+	"github.com/Caritas-Team/reviewer/internal/metrics"
+	"github.com/Caritas-Team/reviewer/internal/storage"
+	"github.com/Caritas-Team/reviewer/internal/usecase/file"
+	"github.com/Caritas-Team/reviewer/internal/usecase/report"
+)
+
+const (
+	formatCSV = "csv"
+	formatPDF = "pdf"
+)
+
+// Export выгружает показатели одной или нескольких операций (id можно повторять);
+// для нескольких заключений добавляются изменения между первым и последним.
+// Все операции должны быть загружены с переданным X-Operation-Key.
+func (h *Handler) Export(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	defer func() { metrics.UpdateExportTime(time.Since(start).Seconds()) }()
+
+	q := r.URL.Query()
+	ids := q["id"]
+	if len(ids) == 0 {
+		metrics.UpdateExportError()
+		writeError(w, http.StatusBadRequest, "missing id parameter")
+		return
+	}
+	format := q.Get("format")
+	if format == "" {
+		format = formatCSV
+	}
+	if format != formatCSV && format != formatPDF {
+		metrics.UpdateExportError()
+		writeError(w, http.StatusBadRequest, "unsupported format: "+format)
+		return
+	}
+	lang := report.ParseLanguage(q.Get("lang"))
+	key := r.Header.Get(OperationKeyHeader)
+	if key == "" {
+		metrics.UpdateExportError()
+		writeError(w, http.StatusBadRequest, "missing "+OperationKeyHeader+" header")
+		return
+	}
+
+	reports := make([]file.DiagnosticReport, 0, len(ids))
+	for _, id := range ids {
+		rep, status, err := h.loadReport(r, id, key)
+		if err != nil {
+			metrics.UpdateExportError()
+			writeError(w, status, err.Error())
+			return
+		}
+		reports = append(reports, rep)
+	}
+
+	var cmp *report.Comparison
+	if len(reports) > 1 {
+		c, err := report.Compare(reports, lang)
+		if err != nil {
+			metrics.UpdateExportError()
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		cmp = &c
+	}
+
+	// Буферизуем, чтобы ошибка записи не превратилась в обрезанный файл с кодом 200
+	var buf bytes.Buffer
+	contentType, filename := "text/csv; charset=utf-8", "report.csv"
+	var err error
+	if format == formatPDF {
+		contentType, filename = "application/pdf", "report.pdf"
+		err = export.RenderPDF(&buf, reports, cmp, lang)
+	} else {
+		bom, _ := strconv.ParseBool(q.Get("bom"))
+		err = report.WriteCSV(&buf, reports, cmp, report.CSVOptions{Language: lang, BOM: bom})
+	}
+	if err != nil {
+		metrics.UpdateExportError()
+		slog.ErrorContext(r.Context(), "export failed", "format", format, "err", err)
+		writeError(w, http.StatusInternalServerError, "failed to build export")
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", `attachment; filename="`+filename+`"`)
+	if _, err := w.Write(buf.Bytes()); err != nil {
+		metrics.UpdateExportError()
+		slog.WarnContext(r.Context(), "export write failed", "format", format, "err", err)
+		return
+	}
+	metrics.UpdateExportSuccess()
+}
+
+// loadReport возвращает извлечённые данные завершённой операции владельца key или HTTP-статус ошибки
+func (h *Handler) loadReport(r *http.Request, id, key string) (file.DiagnosticReport, int, error) {
+	op, err := h.operations.Get(r.Context(), id)
+	if errors.Is(err, storage.ErrNotFound) || (err == nil && !ownedBy(op, key)) {
+		return file.DiagnosticReport{}, http.StatusNotFound, fmt.Errorf("operation %s not found", id)
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "operation load failed", "id", id, "err", err)
+		return file.DiagnosticReport{}, http.StatusInternalServerError, errors.New("failed to load operation")
+	}
+	if op.Status != storage.StatusDone || op.ReportPath == "" {
+		return file.DiagnosticReport{}, http.StatusConflict, fmt.Errorf("operation %s is not processed yet", id)
+	}
+
+	rep, err := file.ReadReport(op.ReportPath)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "report load failed", "id", id, "err", err)
+		return file.DiagnosticReport{}, http.StatusInternalServerError, errors.New("report is unavailable")
+	}
+	return rep, http.StatusOK, nil
+}
@@ -0,0 +1,54 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Caritas-Team/reviewer/internal/config"
+	"github.com/Caritas-Team/reviewer/internal/storage"
+	"github.com/Caritas-Team/reviewer/internal/usecase/file"
+)
+
+func TestExportRequiresOwnerKey(t *testing.T) {
+	h, store, _ := newTestHandler(t, config.Files{})
+	for _, op := range []storage.Operation{{ID: "op-1", OwnerKey: "owner"}, {ID: "op-2", OwnerKey: "other"}} {
+		op.ReportPath = filepath.Join(t.TempDir(), op.ID+".json")
+		err := file.WriteReport(op.ReportPath, file.DiagnosticReport{
+			ChildID:    "ID-0042",
+			TestDate:   time.Date(2025, 1, 10, 0, 0, 0, 0, time.UTC),
+			Indicators: []file.Indicator{{Name: "Внимание", Score: 12}},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		saveOperation(t, store, op, storage.StatusDone, "")
+	}
+
+	tests := []struct {
+		name  string
+		query string
+		key   string
+		want  int
+	}{
+		{name: "owner", query: "id=op-1", key: "owner", want: http.StatusOK},
+		{name: "other key", query: "id=op-1", key: "intruder", want: http.StatusNotFound},
+		{name: "foreign id among own", query: "id=op-1&id=op-2", key: "owner", want: http.StatusNotFound},
+		{name: "missing key", query: "id=op-1", want: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/export?"+tt.query, nil)
+			if tt.key != "" {
+				r.Header.Set(OperationKeyHeader, tt.key)
+			}
+			w := httptest.NewRecorder()
+			h.Export(w, r)
+			if w.Code != tt.want {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.want, w.Body)
+			}
+		})
+	}
+}
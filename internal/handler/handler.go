@@ -1 +1,433 @@
 package handler
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"github.com/Caritas-Team/reviewer/internal/config"
+	"github.com/Caritas-Team/reviewer/internal/logger"
+	"github.com/Caritas-Team/reviewer/internal/metrics"
+	"github.com/Caritas-Team/reviewer/internal/storage"
+	"github.com/Caritas-Team/reviewer/internal/usecase/user"
+	"github.com/google/uuid"
+)
+
+const (
+	// OperationKeyHeader — заголовок с ключом идемпотентности
+	OperationKeyHeader = "X-Operation-Key"
+
+	// filesFormField — имя поля формы с загружаемыми файлами
+	filesFormField = "files"
+
+	// multipartOverhead — запас на заголовки частей multipart-запроса
+	multipartOverhead = 1 << 20
+
+	// sniffLen — сколько байт читается для определения типа содержимого
+	sniffLen = 512
+)
+
+var (
+	ErrTooManyFiles        = errors.New("too many files in request")
+	ErrFileTooLarge        = errors.New("file is too large")
+	ErrUnsupportedMIMEType = errors.New("unsupported file type")
+	ErrNoFiles             = errors.New("no files in request")
+)
+
+type Handler struct {
+	files       atomic.Pointer[config.Files]
+	operations  storage.OperationStore
+	scheduler   scheduler
+	idempotency idempotency
+
+	// closing выставляется при остановке сервиса: новые загрузки получают 503
+	closing atomic.Bool
+}
+
+// scheduler ставит операции в очередь на асинхронную обработку
+type scheduler interface {
+	Submit(op storage.Operation) error
+}
+
+// idempotency закрепляет X-Operation-Key за отпечатком запроса
+type idempotency interface {
+	Reserve(ctx context.Context, key, fingerprint string, ids []string) ([]string, bool, error)
+	Release(ctx context.Context, key string) error
+}
+
+type uploadResponse struct {
+	IDs []string `json:"ids"`
+}
+
+type statusResponse struct {
+	ID     string         `json:"id"`
+	Status storage.Status `json:"status"`
+	Error  *string        `json:"error"`
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+// NewHandler создаёт обработчики HTTP API и каталог для загруженных файлов
+func NewHandler(cfg config.Config, operations storage.OperationStore, scheduler scheduler, idempotency idempotency) (*Handler, error) {
+	files := cfg.Files
+	files.StorageDir = files.Dir()
+	if err := os.MkdirAll(files.StorageDir, 0o750); err != nil {
+		return nil, fmt.Errorf("create storage dir: %w", err)
+	}
+	h := &Handler{
+		operations:  operations,
+		scheduler:   scheduler,
+		idempotency: idempotency,
+	}
+	h.files.Store(&files)
+	return h, nil
+}
+
+// UpdateFiles применяет новые лимиты загрузки: число и размер файлов, допустимые типы.
+// Каталог хранения не меняется — загрузки, начатые до обновления, дописываются по старым лимитам.
+func (h *Handler) UpdateFiles(files config.Files) {
+	next := *h.files.Load()
+	next.MaxFilesPerRequest = files.MaxFilesPerRequest
+	next.MaxFileSize = files.MaxFileSize
+	next.AllowedMIMETypes = files.AllowedMIMETypes
+	h.files.Store(&next)
+}
+
+// StopUploads перестаёт принимать загрузки; уже начатые запросы дорабатывают
+func (h *Handler) StopUploads() {
+	h.closing.Store(true)
+}
+
+// Upload принимает PDF-файлы из поля files и потоково сохраняет их на диск
+func (h *Handler) Upload(w http.ResponseWriter, r *http.Request) {
+	if h.closing.Load() {
+		w.Header().Set("Connection", "close")
+		writeError(w, http.StatusServiceUnavailable, "service is shutting down")
+		return
+	}
+
+	key := r.Header.Get(OperationKeyHeader)
+	if key == "" {
+		writeError(w, http.StatusBadRequest, "missing "+OperationKeyHeader+" header")
+		return
+	}
+
+	files := *h.files.Load()
+	if limit := maxBodySize(files); limit > 0 {
+		r.Body = http.MaxBytesReader(w, r.Body, limit)
+	}
+
+	mr, err := r.MultipartReader()
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "expected multipart/form-data body")
+		return
+	}
+
+	var (
+		ids   []string
+		paths []string
+		sizes []int64
+		sums  [][]byte
+	)
+	cleanup := func() {
+		for _, path := range paths {
+			if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+				slog.WarnContext(r.Context(), "upload cleanup failed", "path", path, "err", err)
+			}
+		}
+	}
+
+	for {
+		part, err := mr.NextPart()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			cleanup()
+			metrics.UpdateFileUploadError()
+			writeError(w, http.StatusBadRequest, "malformed multipart body")
+			return
+		}
+		if part.FormName() != filesFormField {
+			_ = part.Close()
+			continue
+		}
+		if files.MaxFilesPerRequest > 0 && len(ids) >= files.MaxFilesPerRequest {
+			_ = part.Close()
+			cleanup()
+			metrics.UpdateFileUploadError()
+			writeError(w, http.StatusBadRequest, ErrTooManyFiles.Error())
+			return
+		}
+
+		id := uuid.NewString()
+		path := filepath.Join(files.StorageDir, id+".pdf")
+		size, sum, err := saveFile(files, part, path)
+		_ = part.Close()
+		if err != nil {
+			cleanup()
+			metrics.UpdateFileUploadError()
+			h.writeUploadError(w, r, err)
+			return
+		}
+
+		ids = append(ids, id)
+		paths = append(paths, path)
+		sizes = append(sizes, size)
+		sums = append(sums, sum)
+	}
+
+	if len(ids) == 0 {
+		metrics.UpdateFileUploadError()
+		writeError(w, http.StatusBadRequest, ErrNoFiles.Error())
+		return
+	}
+
+	fingerprint := user.Fingerprint(user.ClientIP(r), sums)
+	stored, replay, err := h.idempotency.Reserve(r.Context(), key, fingerprint, ids)
+	if err != nil {
+		cleanup()
+		if errors.Is(err, user.ErrKeyConflict) {
+			writeError(w, http.StatusConflict, err.Error())
+			return
+		}
+		metrics.UpdateFileUploadError()
+		slog.ErrorContext(r.Context(), "operation key reservation failed", "err", err)
+		writeError(w, http.StatusInternalServerError, "failed to reserve operation key")
+		return
+	}
+	if replay {
+		// Повтор того же запроса: файлы уже приняты ранее, новые копии не нужны
+		cleanup()
+		writeJSON(w, http.StatusOK, uploadResponse{IDs: stored})
+		return
+	}
+
+	ops := make([]storage.Operation, 0, len(ids))
+	for i, id := range ids {
+		op, err := storage.SetStatus(r.Context(), h.operations, storage.Operation{
+			ID:        id,
+			FilePath:  paths[i],
+			OwnerKey:  key,
+			RequestID: logger.RequestID(r.Context()),
+		}, storage.StatusNew, "")
+		if err != nil {
+			cleanup()
+			// Без этого повтор с тем же ключом получил бы идентификаторы несохранённых операций
+			if err := h.idempotency.Release(r.Context(), key); err != nil {
+				slog.ErrorContext(r.Context(), "operation key release failed", "err", err)
+			}
+			metrics.UpdateFileUploadError()
+			slog.ErrorContext(r.Context(), "operation save failed", "id", id, "err", err)
+			writeError(w, http.StatusInternalServerError, "failed to register operation")
+			return
+		}
+		ops = append(ops, op)
+	}
+
+	// Операции уже зарегистрированы, поэтому отказ очереди не отменяет запрос, а фиксируется в статусе
+	for _, op := range ops {
+		if err := h.scheduler.Submit(op); err != nil {
+			slog.WarnContext(r.Context(), "operation enqueue failed", "id", op.ID, "err", err)
+			if _, err := storage.SetStatus(r.Context(), h.operations, op, storage.StatusError, err.Error()); err != nil {
+				slog.ErrorContext(r.Context(), "operation save failed", "id", op.ID, "err", err)
+			}
+		}
+	}
+
+	for _, size := range sizes {
+		metrics.UpdateFileSize(float64(size))
+		metrics.UpdateFileUploadSuccess()
+	}
+
+	writeJSON(w, http.StatusOK, uploadResponse{IDs: ids})
+}
+
+// Status возвращает статус операции в JSON или готовый PDF, если клиент просит application/pdf.
+// Операция видна только с тем X-Operation-Key, с которым её загрузили.
+func (h *Handler) Status(w http.ResponseWriter, r *http.Request) {
+	id := r.URL.Query().Get("id")
+	if id == "" {
+		writeError(w, http.StatusBadRequest, "missing id parameter")
+		return
+	}
+	key := r.Header.Get(OperationKeyHeader)
+	if key == "" {
+		writeError(w, http.StatusBadRequest, "missing "+OperationKeyHeader+" header")
+		return
+	}
+
+	op, err := h.operations.Get(r.Context(), id)
+	if errors.Is(err, storage.ErrNotFound) || (err == nil && !ownedBy(op, key)) {
+		writeError(w, http.StatusNotFound, "operation not found")
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "operation load failed", "id", id, "err", err)
+		writeError(w, http.StatusInternalServerError, "failed to load operation")
+		return
+	}
+
+	if op.Status == storage.StatusDone && op.ResultPath != "" && prefersPDF(r.Header.Get("Accept")) {
+		h.servePDF(w, r, op)
+		return
+	}
+
+	resp := statusResponse{ID: op.ID, Status: op.Status}
+	if op.Error != "" {
+		resp.Error = &op.Error
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (h *Handler) servePDF(w http.ResponseWriter, r *http.Request, op storage.Operation) {
+	f, err := os.Open(filepath.Clean(op.ResultPath))
+	if err != nil {
+		slog.ErrorContext(r.Context(), "result open failed", "id", op.ID, "err", err)
+		writeError(w, http.StatusInternalServerError, "result is unavailable")
+		return
+	}
+	defer func() { _ = f.Close() }()
+
+	info, err := f.Stat()
+	if err != nil {
+		slog.ErrorContext(r.Context(), "result stat failed", "id", op.ID, "err", err)
+		writeError(w, http.StatusInternalServerError, "result is unavailable")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/pdf")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", op.ID+".pdf"))
+	http.ServeContent(w, r, op.ID+".pdf", info.ModTime(), f)
+}
+
+// ownedBy сообщает, загружена ли операция с ключом key. Чужая операция отвечает так же, как
+// несуществующая, а сравнение за постоянное время не даёт подбирать ключ по времени ответа.
+func ownedBy(op storage.Operation, key string) bool {
+	return op.OwnerKey != "" && subtle.ConstantTimeCompare([]byte(op.OwnerKey), []byte(key)) == 1
+}
+
+// prefersPDF разбирает заголовок Accept и сообщает, предпочитает ли клиент PDF вместо JSON
+func prefersPDF(accept string) bool {
+	var pdfQ, jsonQ float64
+	for _, item := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(item))
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			if parsed, err := strconv.ParseFloat(v, 64); err == nil {
+				q = parsed
+			}
+		}
+		switch mediaType {
+		case "application/pdf":
+			pdfQ = max(pdfQ, q)
+		case "application/json", "application/*", "*/*":
+			jsonQ = max(jsonQ, q)
+		}
+	}
+	return pdfQ > 0 && pdfQ >= jsonQ
+}
+
+// saveFile проверяет тип содержимого части и копирует её в файл, не превышая лимит размера.
+// Возвращает размер и SHA-256 содержимого.
+func saveFile(files config.Files, part *multipart.Part, path string) (int64, []byte, error) {
+	br := bufio.NewReaderSize(part, sniffLen)
+	head, err := br.Peek(sniffLen)
+	if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, bufio.ErrBufferFull) {
+		return 0, nil, err
+	}
+	if !allowedType(files, part.Header.Get("Content-Type")) || !allowedType(files, http.DetectContentType(head)) {
+		return 0, nil, ErrUnsupportedMIMEType
+	}
+
+	f, err := os.OpenFile(filepath.Clean(path), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+	if err != nil {
+		return 0, nil, fmt.Errorf("create file: %w", err)
+	}
+
+	var src io.Reader = br
+	if files.MaxFileSize > 0 {
+		src = io.LimitReader(br, files.MaxFileSize+1)
+	}
+	hasher := sha256.New()
+	n, err := io.Copy(io.MultiWriter(f, hasher), src)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil && files.MaxFileSize > 0 && n > files.MaxFileSize {
+		err = ErrFileTooLarge
+	}
+	if err != nil {
+		_ = os.Remove(path)
+		return 0, nil, err
+	}
+	return n, hasher.Sum(nil), nil
+}
+
+func allowedType(files config.Files, contentType string) bool {
+	if len(files.AllowedMIMETypes) == 0 {
+		return true
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	for _, allowed := range files.AllowedMIMETypes {
+		if mediaType == allowed {
+			return true
+		}
+	}
+	return false
+}
+
+// maxBodySize — верхняя граница тела запроса, 0 — без ограничения
+func maxBodySize(files config.Files) int64 {
+	if files.MaxFilesPerRequest <= 0 || files.MaxFileSize <= 0 {
+		return 0
+	}
+	return int64(files.MaxFilesPerRequest)*files.MaxFileSize + multipartOverhead
+}
+
+func (h *Handler) writeUploadError(w http.ResponseWriter, r *http.Request, err error) {
+	var maxBytesErr *http.MaxBytesError
+	switch {
+	case errors.Is(err, ErrFileTooLarge), errors.As(err, &maxBytesErr):
+		writeError(w, http.StatusBadRequest, ErrFileTooLarge.Error())
+	case errors.Is(err, ErrUnsupportedMIMEType):
+		writeError(w, http.StatusBadRequest, ErrUnsupportedMIMEType.Error())
+	default:
+		slog.ErrorContext(r.Context(), "upload failed", "err", err)
+		writeError(w, http.StatusInternalServerError, "failed to store file")
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		slog.Error("response encode failed", "err", err)
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, errorResponse{Error: msg})
+}
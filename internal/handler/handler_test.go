@@ -0,0 +1,197 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/Caritas-Team/reviewer/internal/config"
+	"github.com/Caritas-Team/reviewer/internal/memecached"
+	"github.com/Caritas-Team/reviewer/internal/storage"
+	"github.com/Caritas-Team/reviewer/internal/usecase/user"
+)
+
+// queue — планировщик, который только запоминает поставленные операции
+type queue struct {
+	mu  sync.Mutex
+	ops []storage.Operation
+}
+
+func (q *queue) Submit(op storage.Operation) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.ops = append(q.ops, op)
+	return nil
+}
+
+// newTestHandler собирает обработчики поверх кэша в памяти процесса, как при memcached.enable: false
+func newTestHandler(t *testing.T, files config.Files) (*Handler, storage.OperationStore, *queue) {
+	t.Helper()
+	if files.StorageDir == "" {
+		files.StorageDir = t.TempDir()
+	}
+	cfg := config.Config{Files: files, Memcached: config.Memcached{DefaultTTL: 60}}
+	cache, err := memecached.NewCache(context.Background(), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = cache.Close() })
+	operations := storage.NewOperationStore(cfg, cache)
+	q := &queue{}
+	h, err := NewHandler(cfg, operations, q, user.NewIdempotency(cfg, cache))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return h, operations, q
+}
+
+func saveOperation(t *testing.T, store storage.OperationStore, op storage.Operation, status storage.Status, reason string) {
+	t.Helper()
+	if _, err := storage.SetStatus(context.Background(), store, op, status, reason); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func getStatus(h *Handler, id, key string) *httptest.ResponseRecorder {
+	return getStatusAccept(h, id, key, "")
+}
+
+func getStatusAccept(h *Handler, id, key, accept string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodGet, "/status?id="+id, nil)
+	if key != "" {
+		r.Header.Set(OperationKeyHeader, key)
+	}
+	if accept != "" {
+		r.Header.Set("Accept", accept)
+	}
+	w := httptest.NewRecorder()
+	h.Status(w, r)
+	return w
+}
+
+func TestStatusRequiresOwnerKey(t *testing.T) {
+	h, store, _ := newTestHandler(t, config.Files{})
+	saveOperation(t, store, storage.Operation{ID: "op-1", OwnerKey: "owner"}, storage.StatusProgress, "")
+
+	tests := []struct {
+		name string
+		key  string
+		want int
+	}{
+		{name: "owner", key: "owner", want: http.StatusOK},
+		{name: "other key", key: "intruder", want: http.StatusNotFound},
+		{name: "key prefix", key: "own", want: http.StatusNotFound},
+		{name: "missing key", want: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := getStatus(h, "op-1", tt.key)
+			if w.Code != tt.want {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.want, w.Body)
+			}
+		})
+	}
+
+	// Чужая операция неотличима от несуществующей
+	foreign := getStatus(h, "op-1", "intruder").Body.String()
+	if unknown := getStatus(h, "missing", "intruder").Body.String(); foreign != unknown {
+		t.Errorf("foreign operation body %q differs from unknown %q", foreign, unknown)
+	}
+
+	var resp statusResponse
+	if err := json.NewDecoder(getStatus(h, "op-1", "owner").Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.ID != "op-1" || resp.Status != storage.StatusProgress {
+		t.Errorf("response = %+v", resp)
+	}
+}
+
+func TestStatus(t *testing.T) {
+	h, store, _ := newTestHandler(t, config.Files{})
+	result := filepath.Join(t.TempDir(), "op-done.pdf")
+	if err := os.WriteFile(result, []byte("%PDF-1.4\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	saveOperation(t, store, storage.Operation{ID: "op-new", OwnerKey: "k"}, storage.StatusNew, "")
+	saveOperation(t, store, storage.Operation{ID: "op-done", OwnerKey: "k", ResultPath: result}, storage.StatusDone, "")
+	saveOperation(t, store, storage.Operation{ID: "op-failed", OwnerKey: "k"}, storage.StatusError, "file is not a diagnostic report")
+
+	tests := []struct {
+		name       string
+		id         string
+		accept     string
+		wantCode   int
+		wantType   string
+		wantStatus storage.Status
+		wantError  string
+	}{
+		{name: "unknown", id: "op-missing", wantCode: http.StatusNotFound, wantType: "application/json"},
+		{name: "missing id", wantCode: http.StatusBadRequest, wantType: "application/json"},
+		{name: "not ready, pdf wanted", id: "op-new", accept: "application/pdf", wantCode: http.StatusOK,
+			wantType: "application/json", wantStatus: storage.StatusNew},
+		{name: "done as json", id: "op-done", wantCode: http.StatusOK, wantType: "application/json", wantStatus: storage.StatusDone},
+		{name: "done as pdf", id: "op-done", accept: "application/pdf", wantCode: http.StatusOK, wantType: "application/pdf"},
+		{name: "failed", id: "op-failed", accept: "application/pdf", wantCode: http.StatusOK, wantType: "application/json",
+			wantStatus: storage.StatusError, wantError: "file is not a diagnostic report"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := getStatusAccept(h, tt.id, "k", tt.accept)
+			if w.Code != tt.wantCode {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantCode, w.Body)
+			}
+			if got := w.Header().Get("Content-Type"); !strings.HasPrefix(got, tt.wantType) {
+				t.Fatalf("Content-Type = %q, want %s", got, tt.wantType)
+			}
+			if tt.wantStatus == "" {
+				return
+			}
+			var resp statusResponse
+			if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+				t.Fatal(err)
+			}
+			if resp.ID != tt.id || resp.Status != tt.wantStatus {
+				t.Errorf("response = %+v, want %s %s", resp, tt.id, tt.wantStatus)
+			}
+			var got string
+			if resp.Error != nil {
+				got = *resp.Error
+			}
+			if got != tt.wantError {
+				t.Errorf("error = %q, want %q", got, tt.wantError)
+			}
+		})
+	}
+}
+
+func TestPrefersPDF(t *testing.T) {
+	tests := []struct {
+		accept string
+		want   bool
+	}{
+		{"", false},
+		{"application/json", false},
+		{"application/pdf", true},
+		{"application/pdf, application/json", true},
+		{"application/json, application/pdf;q=0.9", false},
+		{"application/pdf;q=0.5, */*;q=0.1", true},
+		{"*/*, application/pdf;q=0.8", false},
+		{"application/pdf;q=0", false},
+		{"application/pdf;q=oops", true},
+		{"application/*;q=0.2, application/pdf", true},
+		{"text/html, application/pdf;q=0.1", true},
+		{"not a media type;;, application/pdf", true},
+	}
+	for _, tt := range tests {
+		if got := prefersPDF(tt.accept); got != tt.want {
+			t.Errorf("prefersPDF(%q) = %v, want %v", tt.accept, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,197 @@
+package handler
+
+import (
+	"cmp"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Caritas-Team/reviewer/internal/metrics"
+	"github.com/Caritas-Team/reviewer/internal/usecase/user"
+)
+
+const (
+	// DefaultMaxIPs — сколько IP-адресов учитывается по отдельности, если не задано в конфиге
+	DefaultMaxIPs = 100
+
+	// otherLabel объединяет IP сверх лимита, а также нестандартные методы
+	otherLabel = "other"
+
+	// unmatchedRoute — запросы, не попавшие ни в один маршрут (404, 405, preflight CORS)
+	unmatchedRoute = "unmatched"
+
+	// ipCandidates — во сколько раз адресов-кандидатов больше, чем учитываемых по отдельности
+	ipCandidates = 4
+	// ipRankInterval — как часто пересчитывается список самых активных адресов
+	ipRankInterval = time.Minute
+)
+
+var knownMethods = map[string]bool{
+	http.MethodGet: true, http.MethodHead: true, http.MethodPost: true, http.MethodPut: true,
+	http.MethodPatch: true, http.MethodDelete: true, http.MethodOptions: true,
+}
+
+// Instrument считает запросы, их длительность и размер ответа по шаблону маршрута и коду.
+// Должен оборачивать ServeMux снаружи: шаблон маршрута становится известен после его отработки.
+// Middleware между Instrument и ServeMux не должны заменять запрос (r.WithContext и т. п.):
+// ServeMux запишет шаблон в копию, и маршрут будет учтён как unmatched. См. Stack.
+func Instrument(maxIPs int) func(http.Handler) http.Handler {
+	if maxIPs <= 0 {
+		maxIPs = DefaultMaxIPs
+	}
+	ips := newIPLabels(maxIPs, metrics.RemoveRequestCountByIP)
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			rec := &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+			next.ServeHTTP(rec, r)
+
+			metrics.UpdateHTTPRequest(routeLabel(r), methodLabel(r.Method), strconv.Itoa(rec.status),
+				time.Since(start).Seconds(), float64(rec.size))
+			metrics.UpdateRequestCountByIP(ips.label(user.ClientIP(r), start))
+			metrics.UpdateOperationsPerSecond()
+		})
+	}
+}
+
+// routeLabel берёт шаблон, выбранный ServeMux, без метода: "GET /status" → "/status"
+func routeLabel(r *http.Request) string {
+	if r.Pattern == "" {
+		return unmatchedRoute
+	}
+	if _, path, ok := strings.Cut(r.Pattern, " "); ok {
+		return path
+	}
+	return r.Pattern
+}
+
+func methodLabel(method string) string {
+	if knownMethods[method] {
+		return method
+	}
+	return otherLabel
+}
+
+// ipLabels ограничивает число серий per-IP: по отдельности учитываются max самых активных адресов,
+// остальные — под меткой "other". Заголовок X-Client-IP задаёт клиент, поэтому без ограничения
+// сканирование создало бы серию на каждый запрос.
+//
+// Частоты оцениваются алгоритмом Space-Saving на ipCandidates*max адресах. Раз в ipRankInterval
+// список пересчитывается: вытесненные адреса теряют свою серию и дальше идут в "other", а счётчики
+// делятся пополам, чтобы в расчёт шла недавняя активность. Пока список не заполнен, новые адреса
+// получают серию сразу.
+type ipLabels struct {
+	mu     sync.Mutex
+	max    int
+	counts map[string]uint64
+	top    map[string]struct{}
+	ranked time.Time
+	remove func(ip string)
+}
+
+func newIPLabels(max int, remove func(ip string)) *ipLabels {
+	return &ipLabels{
+		max:    max,
+		counts: make(map[string]uint64, max*ipCandidates),
+		top:    make(map[string]struct{}, max),
+		ranked: time.Now(),
+		remove: remove,
+	}
+}
+
+func (l *ipLabels) label(ip string, now time.Time) string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.count(ip)
+	if now.Sub(l.ranked) >= ipRankInterval {
+		l.rank(now)
+	}
+	if _, ok := l.top[ip]; ok {
+		return ip
+	}
+	if len(l.top) < l.max {
+		l.top[ip] = struct{}{}
+		return ip
+	}
+	return otherLabel
+}
+
+// count учитывает запрос; новый адрес сверх лимита занимает место самого редкого кандидата
+// и наследует его счётчик, поэтому частый адрес не теряется среди разовых
+func (l *ipLabels) count(ip string) {
+	if _, ok := l.counts[ip]; ok || len(l.counts) < l.max*ipCandidates {
+		l.counts[ip]++
+		return
+	}
+	rarest, least := "", uint64(math.MaxUint64)
+	for candidate, n := range l.counts {
+		if n < least {
+			rarest, least = candidate, n
+		}
+	}
+	delete(l.counts, rarest)
+	l.counts[ip] = least + 1
+}
+
+// rank оставляет отдельные серии только max самым частым кандидатам
+func (l *ipLabels) rank(now time.Time) {
+	ips := make([]string, 0, len(l.counts))
+	for ip := range l.counts {
+		ips = append(ips, ip)
+	}
+	slices.SortFunc(ips, func(a, b string) int { return cmp.Compare(l.counts[b], l.counts[a]) })
+
+	top := make(map[string]struct{}, l.max)
+	for _, ip := range ips[:min(l.max, len(ips))] {
+		top[ip] = struct{}{}
+	}
+	for ip := range l.top {
+		if _, ok := top[ip]; !ok {
+			l.remove(ip)
+		}
+	}
+	l.top = top
+
+	for ip, n := range l.counts {
+		if n /= 2; n == 0 {
+			delete(l.counts, ip)
+			continue
+		}
+		l.counts[ip] = n
+	}
+	l.ranked = now
+}
+
+// responseRecorder запоминает код ответа и число записанных байт
+type responseRecorder struct {
+	http.ResponseWriter
+	status      int
+	size        int64
+	wroteHeader bool
+}
+
+func (r *responseRecorder) WriteHeader(code int) {
+	if !r.wroteHeader {
+		r.status = code
+		r.wroteHeader = true
+	}
+	r.ResponseWriter.WriteHeader(code)
+}
+
+func (r *responseRecorder) Write(b []byte) (int, error) {
+	r.wroteHeader = true
+	n, err := r.ResponseWriter.Write(b)
+	r.size += int64(n)
+	return n, err
+}
+
+// Unwrap даёт http.ResponseController доступ к исходному writer (Flush, дедлайны)
+func (r *responseRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
@@ -0,0 +1,107 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/Caritas-Team/reviewer/internal/metrics"
+	"github.com/Caritas-Team/reviewer/internal/metrics/metricstest"
+)
+
+func TestStackRouteLabel(t *testing.T) {
+	registry := metricstest.UseRegistry(t)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /status", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("ok"))
+	})
+	cors, err := NewCORSPolicy(CORSConfig{AllowedOrigins: []string{"https://app.example.com"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := Stack(mux, cors, Instrument(DefaultMaxIPs))
+
+	for _, path := range []string{"/status", "/status", "/missing"} {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		if rec.Header().Get(RequestIDHeader) == "" {
+			t.Errorf("GET %s: no %s in response", path, RequestIDHeader)
+		}
+	}
+
+	want := map[string]float64{"/status|200": 2, "unmatched|404": 1}
+	got := map[string]float64{}
+	families, err := registry.Gatherer().Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, family := range families {
+		if family.GetName() != metrics.Namespace+"_http_requests_total" {
+			continue
+		}
+		for _, m := range family.GetMetric() {
+			labels := map[string]string{}
+			for _, l := range m.GetLabel() {
+				labels[l.GetName()] = l.GetValue()
+			}
+			got[labels["route"]+"|"+labels["code"]] = m.GetCounter().GetValue()
+		}
+	}
+	for key, value := range want {
+		if got[key] != value {
+			t.Errorf("http_requests_total{route|code=%s} = %v, want %v (all: %v)", key, got[key], value, got)
+		}
+	}
+}
+
+func TestIPLabelsTopN(t *testing.T) {
+	var removed []string
+	l := newIPLabels(2, func(ip string) { removed = append(removed, ip) })
+	now := l.ranked
+
+	// Пока список не заполнен, адреса получают серию сразу
+	for _, ip := range []string{"10.0.0.1", "10.0.0.2"} {
+		if got := l.label(ip, now); got != ip {
+			t.Fatalf("label(%s) = %s, want own series", ip, got)
+		}
+	}
+	for range 10 {
+		l.label("10.0.0.3", now)
+		l.label("10.0.0.1", now)
+	}
+	if got := l.label("10.0.0.3", now); got != otherLabel {
+		t.Fatalf("label(10.0.0.3) before rank = %s, want %s", got, otherLabel)
+	}
+
+	// После пересчёта частый адрес вытесняет редкий
+	now = now.Add(ipRankInterval)
+	if got := l.label("10.0.0.3", now); got != "10.0.0.3" {
+		t.Errorf("label(10.0.0.3) after rank = %s, want own series", got)
+	}
+	if got := l.label("10.0.0.2", now); got != otherLabel {
+		t.Errorf("label(10.0.0.2) after rank = %s, want %s", got, otherLabel)
+	}
+	if len(removed) != 1 || removed[0] != "10.0.0.2" {
+		t.Errorf("removed series = %v, want [10.0.0.2]", removed)
+	}
+
+	// Поток разовых адресов не вытесняет частые и не раздувает число кандидатов
+	for i := range 1000 {
+		if got := l.label("scan-"+strconv.Itoa(i), now); got != otherLabel {
+			t.Fatalf("label(scan-%d) = %s, want %s", i, got, otherLabel)
+		}
+		l.label("10.0.0.1", now)
+		l.label("10.0.0.3", now)
+	}
+	if len(l.counts) > 2*ipCandidates {
+		t.Errorf("%d candidates tracked, want at most %d", len(l.counts), 2*ipCandidates)
+	}
+	now = now.Add(ipRankInterval)
+	for _, ip := range []string{"10.0.0.1", "10.0.0.3"} {
+		if got := l.label(ip, now); got != ip {
+			t.Errorf("label(%s) after scan = %s, want own series", ip, got)
+		}
+	}
+}
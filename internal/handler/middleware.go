@@ -1,25 +1,134 @@
 package handler
 
 import (
+	"fmt"
 	"net/http"
+	"regexp"
+	"slices"
+	"strings"
+	"sync/atomic"
 
 	"github.com/rs/cors"
 )
 
 var (
 	DefaultCORSMethods = []string{"GET", "POST", "PUT", "DELETE", "PATCH", "OPTIONS"}
-	DefaultCORSHeaders = []string{"Content-Type", "Authorization"}
+	DefaultCORSHeaders = []string{"Content-Type", "Authorization", OperationKeyHeader, RequestIDHeader}
 )
 
+// Stack собирает middleware сервера вокруг mux. Порядок важен: RequestID подменяет запрос
+// копией с новым контекстом, а Instrument читает шаблон маршрута, который ServeMux записывает
+// в полученный им запрос, поэтому между Instrument и mux запрос заменять нельзя.
+// instrument может быть nil, если метрики выключены.
+func Stack(mux http.Handler, cors *CORSPolicy, instrument func(http.Handler) http.Handler) http.Handler {
+	h := cors.Middleware(mux)
+	if instrument != nil {
+		h = instrument(h)
+	}
+	return RequestID(h)
+}
+
+// OriginRegexPrefix помечает origin-шаблон как регулярное выражение; оно сравнивается с origin целиком
+const OriginRegexPrefix = "regex:"
+
+// CORSConfig — политика CORS. Origins задаются точно ("https://app.example.com"), шаблоном
+// с одной звёздочкой ("https://*.preview.example.com") или регулярным выражением с префиксом regex:.
 type CORSConfig struct {
 	AllowedOrigins   []string
 	AllowedMethods   []string
 	AllowedHeaders   []string
+	ExposedHeaders   []string
 	AllowCredentials bool
 	MaxAgeSeconds    int
+	Routes           []CORSRoute
+}
+
+// CORSRoute переопределяет политику для пути: точного или, если Path оканчивается на "/", для всех
+// вложенных. Deny запрещает кросс-доменный доступ; пустые списки наследуются от общей политики.
+type CORSRoute struct {
+	Path           string
+	Deny           bool
+	AllowedOrigins []string
+	AllowedMethods []string
+}
+
+// CORSPolicy — CORS-политика, которую можно заменить без перезапуска сервера
+type CORSPolicy struct {
+	policies atomic.Pointer[corsPolicies]
+}
+
+func NewCORSPolicy(cfg CORSConfig) (*CORSPolicy, error) {
+	p := &CORSPolicy{}
+	if err := p.Update(cfg); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// Update применяет новую политику к следующим запросам; при ошибке остаётся прежняя
+func (p *CORSPolicy) Update(cfg CORSConfig) error {
+	policies, err := newCORSPolicies(cfg)
+	if err != nil {
+		return err
+	}
+	p.policies.Store(policies)
+	return nil
+}
+
+func (p *CORSPolicy) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p.policies.Load().match(r.URL.Path).ServeHTTP(w, r, next.ServeHTTP)
+	})
+}
+
+type corsPolicies struct {
+	base   *cors.Cors
+	routes []corsRoute // от длинного пути к короткому
+}
+
+type corsRoute struct {
+	path string
+	c    *cors.Cors
+}
+
+func newCORSPolicies(cfg CORSConfig) (*corsPolicies, error) {
+	base, err := newCORS(cfg)
+	if err != nil {
+		return nil, err
+	}
+	policies := &corsPolicies{base: base}
+	for _, route := range cfg.Routes {
+		routeCfg := cfg
+		switch {
+		case route.Deny:
+			routeCfg.AllowedOrigins = nil
+		case len(route.AllowedOrigins) > 0:
+			routeCfg.AllowedOrigins = route.AllowedOrigins
+		}
+		if len(route.AllowedMethods) > 0 {
+			routeCfg.AllowedMethods = route.AllowedMethods
+		}
+		c, err := newCORS(routeCfg)
+		if err != nil {
+			return nil, fmt.Errorf("cors route %s: %w", route.Path, err)
+		}
+		policies.routes = append(policies.routes, corsRoute{path: route.Path, c: c})
+	}
+	slices.SortStableFunc(policies.routes, func(a, b corsRoute) int { return len(b.path) - len(a.path) })
+	return policies, nil
+}
+
+// match выбирает политику самого длинного подходящего маршрута, как ServeMux
+func (p *corsPolicies) match(path string) *cors.Cors {
+	for _, route := range p.routes {
+		if path == route.path || strings.HasSuffix(route.path, "/") && strings.HasPrefix(path, route.path) {
+			return route.c
+		}
+	}
+	return p.base
 }
 
-func CORS(cfg CORSConfig) func(http.Handler) http.Handler {
+func newCORS(cfg CORSConfig) (*cors.Cors, error) {
 	if len(cfg.AllowedMethods) == 0 {
 		cfg.AllowedMethods = DefaultCORSMethods
 	}
@@ -27,17 +136,75 @@ func CORS(cfg CORSConfig) func(http.Handler) http.Handler {
 		cfg.AllowedHeaders = DefaultCORSHeaders
 	}
 
-	if len(cfg.AllowedOrigins) == 0 {
-		c := cors.AllowAll()
-		return func(next http.Handler) http.Handler { return c.Handler(next) }
-	}
-
-	c := cors.New(cors.Options{
-		AllowedOrigins:   cfg.AllowedOrigins,
+	opts := cors.Options{
 		AllowedMethods:   cfg.AllowedMethods,
 		AllowedHeaders:   cfg.AllowedHeaders,
+		ExposedHeaders:   cfg.ExposedHeaders,
 		AllowCredentials: cfg.AllowCredentials,
 		MaxAge:           cfg.MaxAgeSeconds,
-	})
-	return func(next http.Handler) http.Handler { return c.Handler(next) }
+	}
+	if slices.Contains(cfg.AllowedOrigins, "*") {
+		opts.AllowedOrigins = []string{"*"}
+		return cors.New(opts), nil
+	}
+	// Пустой список для rs/cors означает «разрешить всё»; у нас — запретить кросс-доменные запросы,
+	// поэтому origins всегда проверяет собственная функция
+	matcher, err := newOriginMatcher(cfg.AllowedOrigins)
+	if err != nil {
+		return nil, err
+	}
+	opts.AllowOriginFunc = matcher.match
+	return cors.New(opts), nil
+}
+
+// originMatcher проверяет origin по точным значениям, шаблонам с «*» и регулярным выражениям
+type originMatcher struct {
+	exact     map[string]bool
+	wildcards [][2]string // префикс и суффикс вокруг «*»
+	regexps   []*regexp.Regexp
+}
+
+func newOriginMatcher(origins []string) (*originMatcher, error) {
+	m := &originMatcher{exact: make(map[string]bool)}
+	for _, origin := range origins {
+		if expr, ok := strings.CutPrefix(origin, OriginRegexPrefix); ok {
+			// Якоря обязательны: без них "example\.com" пропустил бы и https://example.com.evil.net
+			re, err := regexp.Compile("^(?:" + expr + ")$")
+			if err != nil {
+				return nil, fmt.Errorf("origin pattern %q: %w", origin, err)
+			}
+			m.regexps = append(m.regexps, re)
+			continue
+		}
+		origin = strings.ToLower(origin)
+		prefix, suffix, ok := strings.Cut(origin, "*")
+		switch {
+		case !ok:
+			m.exact[origin] = true
+		case strings.Contains(suffix, "*"):
+			return nil, fmt.Errorf("origin pattern %q: only one \"*\" is allowed", origin)
+		default:
+			m.wildcards = append(m.wildcards, [2]string{prefix, suffix})
+		}
+	}
+	return m, nil
+}
+
+func (m *originMatcher) match(origin string) bool {
+	lower := strings.ToLower(origin)
+	if m.exact[lower] {
+		return true
+	}
+	for _, w := range m.wildcards {
+		// «*» должна заменять хотя бы один символ
+		if len(lower) > len(w[0])+len(w[1]) && strings.HasPrefix(lower, w[0]) && strings.HasSuffix(lower, w[1]) {
+			return true
+		}
+	}
+	for _, re := range m.regexps {
+		if re.MatchString(origin) {
+			return true
+		}
+	}
+	return false
 }
@@ -0,0 +1,45 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/Caritas-Team/reviewer/internal/logger"
+	"github.com/google/uuid"
+)
+
+const (
+	// RequestIDHeader — заголовок с идентификатором запроса: принимается от клиента и возвращается в ответе
+	RequestIDHeader = "X-Request-UUID"
+
+	maxRequestIDLen = 128
+)
+
+// RequestID берёт идентификатор запроса из X-Request-UUID или создаёт новый, возвращает его
+// в ответе и кладёт в контекст, откуда его берут логгер и загрузка файлов
+func RequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(RequestIDHeader)
+		if !validRequestID(id) {
+			id = uuid.NewString()
+		}
+		w.Header().Set(RequestIDHeader, id)
+		next.ServeHTTP(w, r.WithContext(logger.WithRequestID(r.Context(), id)))
+	})
+}
+
+// validRequestID допускает только короткие идентификаторы из безопасных символов:
+// значение от клиента попадает в логи и записи операций
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLen {
+		return false
+	}
+	for _, c := range []byte(id) {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		case c == '-', c == '_', c == '.', c == ':':
+		default:
+			return false
+		}
+	}
+	return true
+}
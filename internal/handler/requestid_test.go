@@ -0,0 +1,57 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Caritas-Team/reviewer/internal/logger"
+	"github.com/google/uuid"
+)
+
+func TestRequestID(t *testing.T) {
+	tests := []struct {
+		name string
+		id   string
+		keep bool
+	}{
+		{name: "uuid", id: "3f6c1a52-9d1e-4c1b-8f0e-2b7a4e1d9c10", keep: true},
+		{name: "safe characters", id: "frontend:req_42.a-b", keep: true},
+		{name: "longest", id: strings.Repeat("a", maxRequestIDLen), keep: true},
+		{name: "missing"},
+		{name: "too long", id: strings.Repeat("a", maxRequestIDLen+1)},
+		{name: "space", id: "req 42"},
+		{name: "log injection", id: "req\nlevel=ERROR"},
+		{name: "quote", id: `req"42`},
+		{name: "non-ascii", id: "запрос"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var inContext string
+			h := RequestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				inContext = logger.RequestID(r.Context())
+			}))
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.id != "" {
+				r.Header.Set(RequestIDHeader, tt.id)
+			}
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+
+			got := w.Header().Get(RequestIDHeader)
+			if got != inContext {
+				t.Errorf("response id %q differs from context id %q", got, inContext)
+			}
+			if tt.keep {
+				if got != tt.id {
+					t.Errorf("id = %q, want the client's %q", got, tt.id)
+				}
+				return
+			}
+			if _, err := uuid.Parse(got); err != nil || got == tt.id {
+				t.Errorf("id = %q, want a generated UUID", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,196 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/Caritas-Team/reviewer/internal/config"
+	"github.com/Caritas-Team/reviewer/internal/storage"
+	"github.com/Caritas-Team/reviewer/internal/usecase/user"
+)
+
+var testPDF = []byte("%PDF-1.4\n% synthetic\n")
+
+type uploadPart struct {
+	contentType string
+	data        []byte
+}
+
+func pdfPart() uploadPart {
+	return uploadPart{contentType: "application/pdf", data: testPDF}
+}
+
+func uploadRequest(t *testing.T, key string, parts ...uploadPart) *http.Request {
+	t.Helper()
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	for i, p := range parts {
+		header := textproto.MIMEHeader{}
+		header.Set("Content-Disposition", fmt.Sprintf(`form-data; name="files"; filename="report-%d.pdf"`, i))
+		header.Set("Content-Type", p.contentType)
+		w, err := mw.CreatePart(header)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write(p.data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := mw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	r := httptest.NewRequest(http.MethodPost, "/upload", &body)
+	r.Header.Set("Content-Type", mw.FormDataContentType())
+	if key != "" {
+		r.Header.Set(OperationKeyHeader, key)
+	}
+	return r
+}
+
+func TestUpload(t *testing.T) {
+	files := config.Files{
+		MaxFilesPerRequest: 2,
+		MaxFileSize:        64,
+		AllowedMIMETypes:   []string{"application/pdf"},
+		StorageDir:         t.TempDir(),
+	}
+	h, store, q := newTestHandler(t, files)
+
+	w := httptest.NewRecorder()
+	h.Upload(w, uploadRequest(t, "key-1", pdfPart(), pdfPart()))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", w.Code, w.Body)
+	}
+	var resp uploadResponse
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.IDs) != 2 || resp.IDs[0] == resp.IDs[1] {
+		t.Fatalf("ids = %v, want two distinct ids", resp.IDs)
+	}
+	for i, id := range resp.IDs {
+		op, err := store.Get(context.Background(), id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if op.Status != storage.StatusNew || op.OwnerKey != "key-1" {
+			t.Errorf("operation %s = %+v, want NEW owned by key-1", id, op)
+		}
+		data, err := os.ReadFile(op.FilePath)
+		if err != nil || !bytes.Equal(data, testPDF) {
+			t.Errorf("stored file %s = %q, %v", op.FilePath, data, err)
+		}
+		if q.ops[i].ID != id {
+			t.Errorf("queued %s, want %s", q.ops[i].ID, id)
+		}
+	}
+}
+
+func TestUploadRejects(t *testing.T) {
+	tests := []struct {
+		name  string
+		key   string
+		parts []uploadPart
+		want  string
+	}{
+		{name: "missing key", parts: []uploadPart{pdfPart()}, want: "missing " + OperationKeyHeader},
+		{name: "no files", key: "k", want: ErrNoFiles.Error()},
+		{name: "too many files", key: "k", parts: []uploadPart{pdfPart(), pdfPart(), pdfPart()}, want: ErrTooManyFiles.Error()},
+		{name: "too large", key: "k", parts: []uploadPart{
+			pdfPart(),
+			{contentType: "application/pdf", data: append(bytes.Clone(testPDF), bytes.Repeat([]byte("x"), 64)...)},
+		}, want: ErrFileTooLarge.Error()},
+		{name: "declared type", key: "k", parts: []uploadPart{{contentType: "text/plain", data: testPDF}}, want: ErrUnsupportedMIMEType.Error()},
+		{name: "sniffed type", key: "k", parts: []uploadPart{{contentType: "application/pdf", data: []byte("Показатель;Балл\n")}}, want: ErrUnsupportedMIMEType.Error()},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			h, _, q := newTestHandler(t, config.Files{
+				MaxFilesPerRequest: 2,
+				MaxFileSize:        64,
+				AllowedMIMETypes:   []string{"application/pdf"},
+				StorageDir:         dir,
+			})
+			w := httptest.NewRecorder()
+			h.Upload(w, uploadRequest(t, tt.key, tt.parts...))
+			if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), tt.want) {
+				t.Fatalf("response = %d %s, want 400 %q", w.Code, w.Body, tt.want)
+			}
+			// Файлы отклонённого запроса, в том числе уже принятые части, удалены
+			if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+				t.Errorf("files left after rejection: %v", entries)
+			}
+			if len(q.ops) != 0 {
+				t.Errorf("operations queued: %v", q.ops)
+			}
+		})
+	}
+}
+
+func TestUploadShuttingDown(t *testing.T) {
+	h, _, _ := newTestHandler(t, config.Files{})
+	h.StopUploads()
+	w := httptest.NewRecorder()
+	h.Upload(w, uploadRequest(t, "k", pdfPart()))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("status = %d, want 503", w.Code)
+	}
+}
+
+func TestUploadIdempotency(t *testing.T) {
+	dir := t.TempDir()
+	h, _, q := newTestHandler(t, config.Files{
+		MaxFilesPerRequest: 2,
+		MaxFileSize:        64,
+		AllowedMIMETypes:   []string{"application/pdf"},
+		StorageDir:         dir,
+	})
+	upload := func(r *http.Request) (int, uploadResponse) {
+		w := httptest.NewRecorder()
+		h.Upload(w, r)
+		var resp uploadResponse
+		if w.Code == http.StatusOK {
+			if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return w.Code, resp
+	}
+
+	code, first := upload(uploadRequest(t, "k", pdfPart()))
+	if code != http.StatusOK {
+		t.Fatalf("first upload: %d", code)
+	}
+	code, replay := upload(uploadRequest(t, "k", pdfPart()))
+	if code != http.StatusOK || !slices.Equal(replay.IDs, first.IDs) {
+		t.Fatalf("replay = %d %v, want 200 %v", code, replay.IDs, first.IDs)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 1 || len(q.ops) != 1 {
+		t.Errorf("replay stored %d files and queued %d operations, want the original only", len(entries), len(q.ops))
+	}
+
+	// Тот же ключ с другим содержимым или от другого клиента — другой запрос
+	other := uploadRequest(t, "k", uploadPart{contentType: "application/pdf", data: append(bytes.Clone(testPDF), '1')})
+	if code, _ := upload(other); code != http.StatusConflict {
+		t.Errorf("different files: %d, want 409", code)
+	}
+	fromElsewhere := uploadRequest(t, "k", pdfPart())
+	fromElsewhere.Header.Set(user.ClientIPHeader, "203.0.113.7")
+	if code, _ := upload(fromElsewhere); code != http.StatusConflict {
+		t.Errorf("different client: %d, want 409", code)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 1 || len(q.ops) != 1 {
+		t.Errorf("conflicting uploads left %d files and %d queued operations", len(entries), len(q.ops))
+	}
+}
@@ -0,0 +1,81 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// Hook — шаг остановки; ctx ограничен общим дедлайном завершения
+type Hook func(ctx context.Context) error
+
+type hook struct {
+	name string
+	fn   Hook
+}
+
+// Manager запускает сервис и по SIGINT/SIGTERM останавливает его компоненты
+// в порядке регистрации, укладываясь в один общий дедлайн
+type Manager struct {
+	timeout time.Duration
+	hooks   []hook
+}
+
+// NewManager создаёт менеджер; timeout <= 0 — ждать завершения без ограничения
+func NewManager(timeout time.Duration) *Manager {
+	return &Manager{timeout: timeout}
+}
+
+// OnShutdown добавляет шаг остановки; шаги выполняются в порядке добавления
+func (m *Manager) OnShutdown(name string, fn Hook) {
+	m.hooks = append(m.hooks, hook{name: name, fn: fn})
+}
+
+// Run выполняет serve до сигнала остановки или его ошибки, затем все шаги остановки.
+// serve должен возвращаться после остановки сервера одним из шагов.
+func (m *Manager) Run(ctx context.Context, serve func() error) error {
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	served := make(chan error, 1)
+	go func() { served <- serve() }()
+
+	var serveErr error
+	select {
+	case <-ctx.Done():
+		slog.Info("shutdown signal received", "timeout", m.timeout)
+	case serveErr = <-served:
+		served = nil
+	}
+	// Повторный сигнал завершает процесс сразу, без ожидания очереди
+	stop()
+
+	shutdownCtx := context.WithoutCancel(ctx)
+	if m.timeout > 0 {
+		var cancel context.CancelFunc
+		shutdownCtx, cancel = context.WithTimeout(shutdownCtx, m.timeout)
+		defer cancel()
+	}
+
+	errs := []error{serveErr}
+	for _, h := range m.hooks {
+		start := time.Now()
+		if err := h.fn(shutdownCtx); err != nil {
+			slog.Error("shutdown step failed", "step", h.name, "err", err)
+			errs = append(errs, err)
+			continue
+		}
+		slog.Info("shutdown step completed", "step", h.name, "duration", time.Since(start))
+	}
+
+	if served != nil {
+		if err := <-served; err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
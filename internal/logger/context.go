@@ -0,0 +1,45 @@
+package logger
+
+import (
+	"context"
+	"log/slog"
+)
+
+// RequestIDKey — имя атрибута записи лога с идентификатором запроса
+const RequestIDKey = "request_id"
+
+type requestIDKey struct{}
+
+// WithRequestID сохраняет идентификатор запроса в контексте; пустой id контекст не меняет
+func WithRequestID(ctx context.Context, id string) context.Context {
+	if id == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestID возвращает идентификатор запроса из контекста или пустую строку
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// contextHandler добавляет к записям request_id из контекста, переданного в slog.*Context
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if id := RequestID(ctx); id != "" {
+		r.AddAttrs(slog.String(RequestIDKey, id))
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
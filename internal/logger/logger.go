@@ -12,6 +12,7 @@ import (
 type Logger struct {
 	mu     sync.Mutex
 	logger *slog.Logger
+	level  *slog.LevelVar
 }
 
 // Создание логгера
@@ -36,10 +37,9 @@ func NewLogger(cfg config.Config) *Logger {
 	}
 
 	// Проверяем корректность уровня логирования
-	validLevels := map[string]bool{"debug": true, "info": true, "warn": true, "error": true}
-	if !validLevels[localLevel] {
+	level, ok := parseLevel(localLevel)
+	if !ok {
 		slog.Warn("Некорректный уровень логирования в конфигурации, используем 'debug'.", "provided_level", localLevel)
-		localLevel = "debug"
 	}
 
 	// Проверка корректности формата логирования
@@ -49,44 +49,67 @@ func NewLogger(cfg config.Config) *Logger {
 		localFormat = "json"
 	}
 
-	// Определяем уровень логирования
-	var level slog.Level
-	switch localLevel {
-	case "info":
-		level = slog.LevelInfo
-	case "warn":
-		level = slog.LevelWarn
-	case "error":
-		level = slog.LevelError
-	default:
-		level = slog.LevelDebug
-	}
+	// Уровень хранится в LevelVar, чтобы его можно было поменять без пересоздания логгера
+	levelVar := new(slog.LevelVar)
+	levelVar.Set(level)
 
 	// Определяем формат логирования
 	var handler slog.Handler
 	if localFormat == "text" {
 		handler = slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
 			AddSource: true,
-			Level:     level,
+			Level:     levelVar,
 		})
 	} else {
 		handler = slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{
 			AddSource: true,
-			Level:     level,
+			Level:     levelVar,
 		})
 	}
 
 	return &Logger{
-		logger: slog.New(handler),
+		logger: slog.New(contextHandler{handler}),
+		level:  levelVar,
+	}
+}
+
+// SetLevel меняет уровень логирования на лету; пустой уровень — debug, как в NewLogger,
+// некорректный игнорируется
+func (l *Logger) SetLevel(name string) {
+	if name == "" {
+		name = "debug"
+	}
+	level, ok := parseLevel(name)
+	if !ok {
+		slog.Warn("Некорректный уровень логирования, оставляем текущий.", "provided_level", name)
+		return
+	}
+	l.level.Set(level)
+}
+
+// parseLevel переводит уровень из конфигурации в slog.Level; для неизвестного — debug и false
+func parseLevel(name string) (slog.Level, bool) {
+	switch name {
+	case "debug":
+		return slog.LevelDebug, true
+	case "info":
+		return slog.LevelInfo, true
+	case "warn":
+		return slog.LevelWarn, true
+	case "error":
+		return slog.LevelError, true
+	default:
+		return slog.LevelDebug, false
 	}
 }
 
 // Глобальная переменная логгера
 var GlobalLogger *Logger
 
-// Инициализация глобального логгера
+// Инициализация глобального логгера; он же становится логгером slog по умолчанию
 func InitGlobalLogger(cfg config.Config) {
 	GlobalLogger = NewLogger(cfg)
+	slog.SetDefault(GlobalLogger.logger)
 }
 
 // Методы логирования с мьютексом
@@ -0,0 +1,156 @@
+package memecached
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/bradfitz/gomemcache/memcache"
+)
+
+const (
+	// DefaultChunkSize — порог разбиения по умолчанию: с запасом меньше лимита
+	// memcached в 1 МБ, который учитывает ещё ключ и служебные поля
+	DefaultChunkSize = 1000 * 1024
+
+	// flagChunked помечает запись-манифест, за которой стоят части значения
+	flagChunked uint32 = 1 << 0
+)
+
+// ErrCorruptedValue — значение нельзя восстановить: часть вытеснена, не сходится контрольная
+// сумма или не расшифровывается. Оборачивается вместе с memcache.ErrCacheMiss: для вызывающего
+// такое значение равно отсутствующему.
+var ErrCorruptedValue = errors.New("cached value is incomplete or corrupted")
+
+// manifest описывает значение, разбитое на части. Generation уникален для каждой записи,
+// поэтому части конкурентных Set одного ключа не смешиваются.
+type manifest struct {
+	Generation string `json:"gen"`
+	Chunks     int    `json:"chunks"`
+	Size       int    `json:"size"`
+	SHA256     string `json:"sha256"`
+}
+
+func (m manifest) keys(prefix string) []string {
+	keys := make([]string, m.Chunks)
+	for i := range keys {
+		keys[i] = prefix + ":chunk:" + m.Generation + ":" + strconv.Itoa(i)
+	}
+	return keys
+}
+
+// split кодирует значение и возвращает запись для основного ключа. Значение больше chunkSize сначала
+// записывается частями, и тогда запись содержит манифест, а chunks — ключи частей,
+// которые нужно удалить, если основную запись сохранить не удастся.
+func (c *Cache) split(prefix string, value []byte, ttl time.Duration) (*memcache.Item, []string, error) {
+	value, flags, err := c.codec.encode(prefix, value)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(value) <= c.chunkSize {
+		return &memcache.Item{Key: prefix, Value: value, Flags: flags, Expiration: expiration(ttl)}, nil, nil
+	}
+
+	gen := make([]byte, 8)
+	if _, err = rand.Read(gen); err != nil {
+		return nil, nil, fmt.Errorf("chunk generation: %w", err)
+	}
+	sum := sha256.Sum256(value)
+	m := manifest{
+		Generation: hex.EncodeToString(gen),
+		Chunks:     (len(value) + c.chunkSize - 1) / c.chunkSize,
+		Size:       len(value),
+		SHA256:     hex.EncodeToString(sum[:]),
+	}
+	keys := m.keys(prefix)
+
+	start := time.Now()
+	for i, key := range keys {
+		end := min((i+1)*c.chunkSize, len(value))
+		err := c.client.Set(&memcache.Item{Key: key, Value: value[i*c.chunkSize : end], Expiration: expiration(ttl)})
+		if err != nil {
+			observe(opSet, start, err)
+			c.deleteChunks(keys[:i])
+			return nil, nil, err
+		}
+	}
+	observe(opSet, start, nil)
+
+	data, err := json.Marshal(m)
+	if err != nil {
+		c.deleteChunks(keys)
+		return nil, nil, fmt.Errorf("encode chunk manifest: %w", err)
+	}
+	return &memcache.Item{Key: prefix, Value: data, Flags: flags | flagChunked, Expiration: expiration(ttl)}, keys, nil
+}
+
+// assemble возвращает декодированное значение записи, собирая его из частей, если запись — манифест
+func (c *Cache) assemble(item *memcache.Item) ([]byte, error) {
+	if item.Flags&flagChunked == 0 {
+		return c.codec.decode(item.Key, item.Value, item.Flags)
+	}
+	var m manifest
+	if err := json.Unmarshal(item.Value, &m); err != nil {
+		return nil, corrupted("manifest: " + err.Error())
+	}
+	keys := m.keys(item.Key)
+
+	start := time.Now()
+	items, err := c.client.GetMulti(keys)
+	observe(opGet, start, err)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	buf.Grow(m.Size)
+	for i, key := range keys {
+		chunk, ok := items[key]
+		if !ok {
+			return nil, corrupted(fmt.Sprintf("chunk %d of %d is missing", i+1, m.Chunks))
+		}
+		buf.Write(chunk.Value)
+	}
+	value := buf.Bytes()
+	sum := sha256.Sum256(value)
+	if len(value) != m.Size || hex.EncodeToString(sum[:]) != m.SHA256 {
+		return nil, corrupted("checksum mismatch")
+	}
+	return c.codec.decode(item.Key, value, item.Flags)
+}
+
+// chunkKeys возвращает ключи частей значения, если по ключу лежит манифест. Чтение учитывается
+// в метриках операции, ради которой понадобились части.
+func (c *Cache) chunkKeys(operation, prefix string) []string {
+	start := time.Now()
+	item, err := c.client.Get(prefix)
+	observe(operation, start, err)
+	if err != nil {
+		return nil
+	}
+	return c.manifestKeys(item)
+}
+
+func (c *Cache) manifestKeys(item *memcache.Item) []string {
+	if item.Flags&flagChunked == 0 {
+		return nil
+	}
+	var m manifest
+	if err := json.Unmarshal(item.Value, &m); err != nil {
+		return nil
+	}
+	return m.keys(item.Key)
+}
+
+// deleteChunks удаляет части, не ставшие значением; не удалённые истекут вместе с TTL
+func (c *Cache) deleteChunks(keys []string) {
+	for _, key := range keys {
+		_ = c.client.Delete(key)
+	}
+}
@@ -0,0 +1,179 @@
+package memecached
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Caritas-Team/reviewer/internal/memecached/memecachedtest"
+	"github.com/bradfitz/gomemcache/memcache"
+)
+
+const testChunkSize = 16
+
+// chunkKeysOf возвращает ключи частей, лежащие на сервере
+func chunkKeysOf(f *memecachedtest.Server) []string {
+	var keys []string
+	for _, key := range f.Keys() {
+		if strings.Contains(key, ":chunk:") {
+			keys = append(keys, key)
+		}
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+func TestChunkedSetGet(t *testing.T) {
+	f := memecachedtest.Start(t)
+	c := newTestCache(t, f, testChunkSize)
+	ctx := context.Background()
+
+	tests := []struct {
+		name   string
+		value  []byte
+		chunks int
+	}{
+		{name: "split", value: bytes.Repeat([]byte("0123456789"), 10), chunks: 7},
+		{name: "overwrite with fewer chunks", value: bytes.Repeat([]byte("ab"), 20), chunks: 3},
+		// Маленькое значение пишется без чтения манифеста: части остаются до истечения TTL
+		{name: "overwrite with small value", value: []byte("small"), chunks: 3},
+		{name: "split again", value: bytes.Repeat([]byte("z"), testChunkSize+1), chunks: 5},
+		{name: "overwrite split value", value: bytes.Repeat([]byte("y"), testChunkSize+1), chunks: 5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := c.Set(ctx, "report", tt.value, time.Minute); err != nil {
+				t.Fatal(err)
+			}
+			got, err := c.Get(ctx, "report")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, tt.value) {
+				t.Errorf("Get = %q, want %q", got, tt.value)
+			}
+			// Части предыдущего разбитого значения удалены
+			if keys := chunkKeysOf(f); len(keys) != tt.chunks {
+				t.Errorf("%d chunks on server, want %d: %v", len(keys), tt.chunks, keys)
+			}
+		})
+	}
+
+	if err := c.Delete(ctx, "report"); err != nil {
+		t.Fatal(err)
+	}
+	// Остаются только части, брошенные маленькой перезаписью
+	if keys := f.Keys(); len(keys) != 3 || len(chunkKeysOf(f)) != 3 {
+		t.Errorf("keys left after Delete: %v, want the 3 orphaned chunks", keys)
+	}
+}
+
+func TestSetSmallValueWithoutRead(t *testing.T) {
+	f := memecachedtest.Start(t)
+	c := newTestCache(t, f, testChunkSize)
+	ctx := context.Background()
+
+	for range 3 {
+		if err := c.Set(ctx, "status", []byte("DONE"), time.Minute); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if reads := f.Reads(); reads != 0 {
+		t.Errorf("%d reads for small Set, want none", reads)
+	}
+}
+
+func TestChunkedCorruption(t *testing.T) {
+	tests := []struct {
+		name    string
+		corrupt func(f *memecachedtest.Server, chunk string)
+	}{
+		{name: "missing chunk", corrupt: func(f *memecachedtest.Server, chunk string) { f.Delete(chunk) }},
+		{name: "checksum mismatch", corrupt: func(f *memecachedtest.Server, chunk string) {
+			f.Update(chunk, bytes.ToUpper)
+		}},
+		{name: "truncated chunk", corrupt: func(f *memecachedtest.Server, chunk string) {
+			f.Update(chunk, func(value []byte) []byte { return value[:1] })
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := memecachedtest.Start(t)
+			c := newTestCache(t, f, testChunkSize)
+			ctx := context.Background()
+
+			if err := c.Set(ctx, "report", bytes.Repeat([]byte("abc"), 20), time.Minute); err != nil {
+				t.Fatal(err)
+			}
+			chunk := chunkKeysOf(f)[1]
+			tt.corrupt(f, chunk)
+
+			_, err := c.Get(ctx, "report")
+			if !errors.Is(err, ErrCorruptedValue) || !errors.Is(err, memcache.ErrCacheMiss) {
+				t.Fatalf("Get error = %v, want corrupted value reported as a miss", err)
+			}
+			values, err := c.GetMulti(ctx, []string{"report"})
+			if err != nil || len(values) != 0 {
+				t.Errorf("GetMulti = %v, %v; want the corrupted key skipped", values, err)
+			}
+		})
+	}
+}
+
+func TestChunkedCompareAndSwap(t *testing.T) {
+	f := memecachedtest.Start(t)
+	c := newTestCache(t, f, testChunkSize)
+	ctx := context.Background()
+
+	if err := c.CompareAndSwap(ctx, "report", time.Minute, func(current []byte) ([]byte, error) {
+		return current, nil
+	}); !errors.Is(err, memcache.ErrCacheMiss) {
+		t.Fatalf("CompareAndSwap of a missing key: err = %v, want ErrCacheMiss", err)
+	}
+
+	initial := bytes.Repeat([]byte("a"), 40)
+	if err := c.Set(ctx, "report", initial, time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	err := c.CompareAndSwap(ctx, "report", time.Minute, func(current []byte) ([]byte, error) {
+		if !bytes.Equal(current, initial) {
+			t.Errorf("update got %q, want %q", current, initial)
+		}
+		return append(current, bytes.Repeat([]byte("b"), 40)...), nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := c.Get(ctx, "report")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := append(bytes.Clone(initial), bytes.Repeat([]byte("b"), 40)...); !bytes.Equal(got, want) {
+		t.Errorf("Get after CompareAndSwap = %q, want %q", got, want)
+	}
+	if keys := chunkKeysOf(f); len(keys) != 5 {
+		t.Errorf("%d chunks on server after CompareAndSwap, want 5: %v", len(keys), keys)
+	}
+
+	// Запись между чтением и CAS даёт конфликт; части проигравшего значения удаляются
+	concurrent := bytes.Repeat([]byte("c"), 20)
+	err = c.CompareAndSwap(ctx, "report", time.Minute, func(current []byte) ([]byte, error) {
+		if err := c.Set(ctx, "report", concurrent, time.Minute); err != nil {
+			t.Fatal(err)
+		}
+		return bytes.Repeat([]byte("d"), 100), nil
+	})
+	if !errors.Is(err, memcache.ErrCASConflict) {
+		t.Fatalf("CompareAndSwap after a concurrent Set: err = %v, want ErrCASConflict", err)
+	}
+	if got, err := c.Get(ctx, "report"); err != nil || !bytes.Equal(got, concurrent) {
+		t.Errorf("Get = %q, %v; want the concurrent value", got, err)
+	}
+	if keys := chunkKeysOf(f); len(keys) != 2 {
+		t.Errorf("%d chunks on server after conflict, want 2: %v", len(keys), keys)
+	}
+}
@@ -0,0 +1,184 @@
+package memecached
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"io"
+
+	"github.com/Caritas-Team/reviewer/internal/config"
+	"github.com/bradfitz/gomemcache/memcache"
+	"github.com/klauspost/compress/zstd"
+)
+
+const (
+	CompressionNone = "none"
+	CompressionGzip = "gzip"
+	CompressionZstd = "zstd"
+
+	// Флаги записи описывают, как закодировано значение, поэтому запись читается
+	// и после смены настроек: сжатие и ключ берутся из самой записи, а не из конфига
+	flagGzip      uint32 = 1 << 1
+	flagZstd      uint32 = 1 << 2
+	flagEncrypted uint32 = 1 << 3
+
+	// maxDecodedSize ограничивает распакованное значение, чтобы испорченная запись не заняла всю память
+	maxDecodedSize = 64 << 20
+)
+
+// codec сжимает и шифрует значения перед записью в memcached.
+// Шифрованная запись: длина id ключа (1 байт) | id ключа | nonce | шифротекст AES-GCM.
+// Ключ memcached входит в AAD, поэтому значение нельзя подменить значением другого ключа.
+type codec struct {
+	compression string
+	keyID       string
+	keys        map[string]cipher.AEAD
+
+	zstdEncoder *zstd.Encoder
+	zstdDecoder *zstd.Decoder
+}
+
+func newCodec(cfg config.Memcached) (*codec, error) {
+	c := &codec{compression: cfg.Compression, keys: make(map[string]cipher.AEAD)}
+	switch cfg.Compression {
+	case "", CompressionNone:
+		c.compression = CompressionNone
+	case CompressionGzip, CompressionZstd:
+	default:
+		return nil, fmt.Errorf("unknown memcached compression %q", cfg.Compression)
+	}
+
+	// Декодер zstd нужен и без сжатия в конфиге: в кэше могут остаться записи, сжатые раньше
+	var err error
+	if c.zstdDecoder, err = zstd.NewReader(nil, zstd.WithDecoderMaxMemory(maxDecodedSize)); err != nil {
+		return nil, fmt.Errorf("zstd decoder: %w", err)
+	}
+	if c.compression == CompressionZstd {
+		if c.zstdEncoder, err = zstd.NewWriter(nil); err != nil {
+			return nil, fmt.Errorf("zstd encoder: %w", err)
+		}
+	}
+
+	// Ключи загружаются и при выключенном шифровании, чтобы прочитать записи, сделанные до выключения
+	for id, encoded := range cfg.Encryption.Keys {
+		if len(id) == 0 || len(id) > 255 {
+			return nil, fmt.Errorf("memcached encryption key id %q must be 1-255 bytes", id)
+		}
+		raw, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("memcached encryption key %q: %w", id, err)
+		}
+		block, err := aes.NewCipher(raw)
+		if err != nil {
+			return nil, fmt.Errorf("memcached encryption key %q: %w", id, err)
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, fmt.Errorf("memcached encryption key %q: %w", id, err)
+		}
+		c.keys[id] = aead
+	}
+	if cfg.Encryption.Enabled {
+		if _, ok := c.keys[cfg.Encryption.KeyID]; !ok {
+			return nil, fmt.Errorf("memcached encryption key_id %q is not in keys", cfg.Encryption.KeyID)
+		}
+		c.keyID = cfg.Encryption.KeyID
+	}
+	return c, nil
+}
+
+// encode применяет сжатие, затем шифрование; возвращает флаги для записи
+func (c *codec) encode(key string, value []byte) ([]byte, uint32, error) {
+	var flags uint32
+	switch c.compression {
+	case CompressionGzip:
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		if _, err := zw.Write(value); err != nil {
+			return nil, 0, fmt.Errorf("gzip value: %w", err)
+		}
+		if err := zw.Close(); err != nil {
+			return nil, 0, fmt.Errorf("gzip value: %w", err)
+		}
+		value, flags = buf.Bytes(), flags|flagGzip
+	case CompressionZstd:
+		value, flags = c.zstdEncoder.EncodeAll(value, nil), flags|flagZstd
+	}
+
+	if c.keyID == "" {
+		return value, flags, nil
+	}
+	aead := c.keys[c.keyID]
+	out := make([]byte, 0, 1+len(c.keyID)+aead.NonceSize()+len(value)+aead.Overhead())
+	out = append(out, byte(len(c.keyID)))
+	out = append(out, c.keyID...)
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, 0, fmt.Errorf("encryption nonce: %w", err)
+	}
+	out = append(out, nonce...)
+	out = aead.Seal(out, nonce, value, []byte(key))
+	return out, flags | flagEncrypted, nil
+}
+
+// decode снимает шифрование и сжатие по флагам записи
+func (c *codec) decode(key string, value []byte, flags uint32) ([]byte, error) {
+	if flags&flagEncrypted != 0 {
+		if len(value) < 1 || len(value) < 1+int(value[0]) {
+			return nil, corrupted("truncated encrypted value")
+		}
+		id := string(value[1 : 1+int(value[0])])
+		aead, ok := c.keys[id]
+		if !ok {
+			return nil, corrupted(fmt.Sprintf("unknown encryption key id %q", id))
+		}
+		rest := value[1+len(id):]
+		if len(rest) < aead.NonceSize() {
+			return nil, corrupted("truncated encrypted value")
+		}
+		plain, err := aead.Open(nil, rest[:aead.NonceSize()], rest[aead.NonceSize():], []byte(key))
+		if err != nil {
+			return nil, corrupted("decrypt: " + err.Error())
+		}
+		value = plain
+	}
+
+	switch {
+	case flags&flagGzip != 0:
+		zr, err := gzip.NewReader(bytes.NewReader(value))
+		if err != nil {
+			return nil, corrupted("gunzip: " + err.Error())
+		}
+		plain, err := io.ReadAll(io.LimitReader(zr, maxDecodedSize+1))
+		if err != nil {
+			return nil, corrupted("gunzip: " + err.Error())
+		}
+		if len(plain) > maxDecodedSize {
+			return nil, corrupted("decoded value is too large")
+		}
+		value = plain
+	case flags&flagZstd != 0:
+		plain, err := c.zstdDecoder.DecodeAll(value, nil)
+		if err != nil {
+			return nil, corrupted("zstd: " + err.Error())
+		}
+		value = plain
+	}
+	return value, nil
+}
+
+func corrupted(reason string) error {
+	return fmt.Errorf("%w: %w: %s", memcache.ErrCacheMiss, ErrCorruptedValue, reason)
+}
+
+// close останавливает горутины декодера zstd
+func (c *codec) close() {
+	c.zstdDecoder.Close()
+	if c.zstdEncoder != nil {
+		_ = c.zstdEncoder.Close()
+	}
+}
@@ -0,0 +1,170 @@
+package memecached
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"testing"
+
+	"github.com/Caritas-Team/reviewer/internal/config"
+	"github.com/bradfitz/gomemcache/memcache"
+)
+
+var (
+	testKey1 = base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{1}, 32))
+	testKey2 = base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{2}, 32))
+)
+
+func newTestCodec(t *testing.T, cfg config.Memcached) *codec {
+	t.Helper()
+	c, err := newCodec(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(c.close)
+	return c
+}
+
+func encrypted(keyID string, keys map[string]string) config.Memcached {
+	return config.Memcached{Encryption: config.Encryption{Enabled: keyID != "", KeyID: keyID, Keys: keys}}
+}
+
+func TestCodecCompression(t *testing.T) {
+	value := bytes.Repeat([]byte("показатель;балл\n"), 100)
+	tests := []struct {
+		compression string
+		flag        uint32
+	}{
+		{compression: "", flag: 0},
+		{compression: CompressionNone, flag: 0},
+		{compression: CompressionGzip, flag: flagGzip},
+		{compression: CompressionZstd, flag: flagZstd},
+	}
+	reader := newTestCodec(t, config.Memcached{})
+	for _, tt := range tests {
+		t.Run("compression="+tt.compression, func(t *testing.T) {
+			c := newTestCodec(t, config.Memcached{Compression: tt.compression})
+			data, flags, err := c.encode("k", value)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if flags != tt.flag {
+				t.Fatalf("flags = %b, want %b", flags, tt.flag)
+			}
+			if tt.flag != 0 && len(data) >= len(value) {
+				t.Errorf("compressed %d bytes into %d", len(value), len(data))
+			}
+			// Запись читается кодеком с любыми настройками: способ сжатия берётся из флагов
+			got, err := reader.decode("k", data, flags)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, value) {
+				t.Errorf("decode = %q, want %q", got, value)
+			}
+		})
+	}
+
+	if _, err := newCodec(config.Memcached{Compression: "lz4"}); err == nil {
+		t.Error("unknown compression accepted")
+	}
+}
+
+func TestCodecEncryption(t *testing.T) {
+	keys := map[string]string{"k1": testKey1}
+	c := newTestCodec(t, config.Memcached{Compression: CompressionGzip, Encryption: encrypted("k1", keys).Encryption})
+	value := []byte(`{"status":"DONE"}`)
+
+	data, flags, err := c.encode("pdf_api:operation:1", value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if flags != flagGzip|flagEncrypted {
+		t.Fatalf("flags = %b, want gzip and encrypted", flags)
+	}
+	if bytes.Contains(data, value) {
+		t.Fatal("ciphertext contains the plain value")
+	}
+	got, err := c.decode("pdf_api:operation:1", data, flags)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, value) {
+		t.Errorf("decode = %q, want %q", got, value)
+	}
+
+	// Ключ memcached входит в AAD: значение, перенесённое под другой ключ, не расшифровывается
+	_, err = c.decode("pdf_api:operation:2", data, flags)
+	if !errors.Is(err, ErrCorruptedValue) || !errors.Is(err, memcache.ErrCacheMiss) {
+		t.Errorf("decode under another key: err = %v, want corrupted value", err)
+	}
+
+	tampered := bytes.Clone(data)
+	tampered[len(tampered)-1] ^= 1
+	if _, err := c.decode("pdf_api:operation:1", tampered, flags); !errors.Is(err, ErrCorruptedValue) {
+		t.Errorf("decode of a tampered value: err = %v, want corrupted value", err)
+	}
+	if _, err := c.decode("pdf_api:operation:1", data[:3], flags); !errors.Is(err, ErrCorruptedValue) {
+		t.Errorf("decode of a truncated value: err = %v, want corrupted value", err)
+	}
+}
+
+func TestCodecKeyRotation(t *testing.T) {
+	old := newTestCodec(t, encrypted("k1", map[string]string{"k1": testKey1}))
+	data, flags, err := old.encode("key", []byte("before rotation"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// После ротации новые записи шифруются k2, а записи под k1 читаются, пока k1 есть в keys
+	rotated := newTestCodec(t, encrypted("k2", map[string]string{"k1": testKey1, "k2": testKey2}))
+	got, err := rotated.decode("key", data, flags)
+	if err != nil || string(got) != "before rotation" {
+		t.Fatalf("decode of an old record = %q, %v", got, err)
+	}
+	fresh, freshFlags, err := rotated.encode("key", []byte("after rotation"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id := string(fresh[1 : 1+int(fresh[0])]); id != "k2" {
+		t.Errorf("new record key id = %q, want k2", id)
+	}
+	if _, err := old.decode("key", fresh, freshFlags); !errors.Is(err, ErrCorruptedValue) {
+		t.Errorf("decode with an unknown key id: err = %v, want corrupted value", err)
+	}
+
+	// Выключенное шифрование не мешает читать записи, сделанные до выключения
+	disabled := newTestCodec(t, encrypted("", map[string]string{"k2": testKey2}))
+	if got, err := disabled.decode("key", fresh, freshFlags); err != nil || string(got) != "after rotation" {
+		t.Errorf("decode with encryption disabled = %q, %v", got, err)
+	}
+	if _, flags, _ := disabled.encode("key", []byte("plain")); flags&flagEncrypted != 0 {
+		t.Error("value encrypted with encryption disabled")
+	}
+
+	// Удалённый из keys ключ делает его записи нечитаемыми
+	retired := newTestCodec(t, encrypted("k2", map[string]string{"k2": testKey2}))
+	if _, err := retired.decode("key", data, flags); !errors.Is(err, ErrCorruptedValue) {
+		t.Errorf("decode under a retired key: err = %v, want corrupted value", err)
+	}
+}
+
+func TestNewCodecRejectsBadKeys(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  config.Memcached
+	}{
+		{name: "key_id not in keys", cfg: encrypted("k2", map[string]string{"k1": testKey1})},
+		{name: "not base64", cfg: encrypted("k1", map[string]string{"k1": "not base64!"})},
+		{name: "wrong key size", cfg: encrypted("k1", map[string]string{"k1": base64.StdEncoding.EncodeToString([]byte("short"))})},
+		{name: "empty key id", cfg: encrypted("", map[string]string{"": testKey1})},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if c, err := newCodec(tt.cfg); err == nil {
+				c.close()
+				t.Fatal("config accepted")
+			}
+		})
+	}
+}
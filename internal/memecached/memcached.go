@@ -2,45 +2,152 @@ package memecached
 
 import (
 	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/Caritas-Team/reviewer/internal/config"
+	"github.com/Caritas-Team/reviewer/internal/metrics"
 	"github.com/bradfitz/gomemcache/memcache"
 )
 
+// Значения метки operation в метриках кэша
+const (
+	opGet      = "get"
+	opGetMulti = "get_multi"
+	opSet      = "set"
+	opAdd      = "add"
+	opCAS      = "cas"
+	opTouch    = "touch"
+	opDelete   = "delete"
+)
+
+// Значения метки result в cache_requests_total
+const (
+	resultOK        = "ok"
+	resultMiss      = "miss"
+	resultNotStored = "not_stored"
+	resultConflict  = "conflict"
+	resultError     = "error"
+)
+
+const (
+	// DefaultHealthInterval — период проверки memcached, если health_interval не задан
+	DefaultHealthInterval = 10 * time.Second
+
+	defaultConnectBackoff = time.Second
+	maxConnectBackoff     = 30 * time.Second
+)
+
 type Cache struct {
-	client *memcache.Client
-	ttl    time.Duration
-	prefix string
-	enable bool
+	client    *memcache.Client
+	ttl       time.Duration
+	prefix    string
+	enable    bool
+	chunkSize int
+	codec     *codec
+
+	// Без memcached (enable: false) и пока degraded значения читаются и пишутся в local; Run возвращает
+	// их в memcached после восстановления. Выбор между local и memcached делается под mode.RLock,
+	// смена режима и перенос значений — под mode.Lock, поэтому на время переноса обращения ждут.
+	mode           sync.RWMutex
+	degraded       atomic.Bool
+	local          *memoryCache
+	healthInterval time.Duration
 }
 
 type CacheInterface interface {
 	Get(ctx context.Context, key string) ([]byte, error)
+	GetMulti(ctx context.Context, keys []string) (map[string][]byte, error)
 	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	Add(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	CompareAndSwap(ctx context.Context, key string, ttl time.Duration, update func(current []byte) ([]byte, error)) error
+	Touch(ctx context.Context, key string, ttl time.Duration) error
+	Delete(ctx context.Context, key string) error
 	Close() error
-	IsHealthy() bool
+	IsHealthy(ctx context.Context) bool
+	Degraded() bool
 }
 
+var _ CacheInterface = (*Cache)(nil)
+
+// NewCache подключается к memcached, повторяя попытки с растущей задержкой. Если memcached так и
+// не ответил, кэш запускается в режиме деградации и работает в памяти процесса до восстановления связи.
+// При memcached.enable: false кэш всё время работает в памяти процесса — это единственное
+// хранилище с TTL для операций и ключей идемпотентности в таком режиме.
 func NewCache(ctx context.Context, cfg config.Config) (*Cache, error) {
 	if err := ctx.Err(); err != nil {
 		return nil, err
 	}
 	if !cfg.Memcached.Enable {
-		return &Cache{enable: false}, nil
+		return &Cache{enable: false, local: newMemoryCache()}, nil
 	} else {
-		client := memcache.New(cfg.Memcached.Servers...)
-
-		if err := client.Ping(); err != nil {
+		codec, err := newCodec(cfg.Memcached)
+		if err != nil {
 			return nil, err
 		}
 
-		return &Cache{
-			client: client,
-			ttl:    time.Duration(cfg.Memcached.DefaultTTL) * time.Second,
-			prefix: cfg.Memcached.KeyPrefix,
-			enable: true,
-		}, nil
+		chunkSize := cfg.Memcached.ChunkSize
+		if chunkSize <= 0 {
+			chunkSize = DefaultChunkSize
+		}
+		healthInterval := cfg.Memcached.HealthInterval()
+		if healthInterval <= 0 {
+			healthInterval = DefaultHealthInterval
+		}
+
+		c := &Cache{
+			client:         memcache.New(cfg.Memcached.Servers...),
+			ttl:            time.Duration(cfg.Memcached.DefaultTTL) * time.Second,
+			prefix:         cfg.Memcached.KeyPrefix,
+			enable:         true,
+			chunkSize:      chunkSize,
+			codec:          codec,
+			local:          newMemoryCache(),
+			healthInterval: healthInterval,
+		}
+
+		if err := c.connect(ctx, cfg.Memcached); err != nil {
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				codec.close()
+				return nil, ctxErr
+			}
+			slog.Warn("memcached is unreachable, serving cache from process memory", "err", err)
+			c.setDegraded(true)
+			return c, nil
+		}
+		c.setDegraded(false)
+		return c, nil
+	}
+}
+
+// connect проверяет связь с memcached, удваивая задержку между попытками
+func (c *Cache) connect(ctx context.Context, cfg config.Memcached) error {
+	attempts := max(cfg.ConnectAttempts, 1)
+	backoff := cfg.ConnectBackoff()
+	if backoff <= 0 {
+		backoff = defaultConnectBackoff
+	}
+
+	var err error
+	for attempt := 1; ; attempt++ {
+		if err = c.client.Ping(); err == nil {
+			return nil
+		}
+		if attempt == attempts {
+			return err
+		}
+		slog.Warn("memcached ping failed, retrying", "attempt", attempt, "of", attempts, "retry_in", backoff, "err", err)
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+		backoff = min(backoff*2, maxConnectBackoff)
 	}
 }
 
@@ -48,37 +155,227 @@ func (c *Cache) Get(ctx context.Context, key string) ([]byte, error) {
 	if err := ctx.Err(); err != nil {
 		return nil, err
 	}
-	if !c.enable || c.client == nil {
-		return nil, memcache.ErrCacheMiss
+	var (
+		value []byte
+		err   error
+	)
+	if c.inLocal(func(local *memoryCache) { value, err = local.get(key) }) {
+		return value, err
 	}
 	prefix := c.prefix + ":" + key
+	start := time.Now()
 	item, err := c.client.Get(prefix)
+	observe(opGet, start, err)
 	if err != nil {
+		lookup(opGet, err)
 		return nil, err
 	}
-	return item.Value, nil
+	value, err = c.assemble(item)
+	lookup(opGet, err)
+	return value, err
+}
+
+// GetMulti читает несколько ключей за один запрос; отсутствующих ключей нет в результате
+func (c *Cache) GetMulti(ctx context.Context, keys []string) (map[string][]byte, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	values := make(map[string][]byte, len(keys))
+	if len(keys) == 0 {
+		return values, nil
+	}
+	if c.inLocal(func(local *memoryCache) {
+		for _, key := range keys {
+			if value, err := local.get(key); err == nil {
+				values[key] = value
+			}
+		}
+	}) {
+		return values, nil
+	}
+	prefixed := make([]string, len(keys))
+	for i, key := range keys {
+		prefixed[i] = c.prefix + ":" + key
+	}
+	start := time.Now()
+	items, err := c.client.GetMulti(prefixed)
+	observe(opGetMulti, start, err)
+	if err != nil {
+		return nil, err
+	}
+	// Попадания и промахи считаются по ключам: ответ без единого найденного ключа — не попадание
+	for i, key := range keys {
+		item, ok := items[prefixed[i]]
+		if !ok {
+			metrics.UpdateCacheMisses(opGetMulti)
+			continue
+		}
+		value, err := c.assemble(item)
+		lookup(opGetMulti, err)
+		if errors.Is(err, memcache.ErrCacheMiss) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		values[key] = value
+	}
+	return values, nil
 }
 
 func (c *Cache) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
 	if err := ctx.Err(); err != nil {
 		return err
 	}
-	if !c.enable || c.client == nil {
-		return memcache.ErrCacheMiss
+	var err error
+	if c.inLocal(func(local *memoryCache) { err = local.set(key, value, ttl) }) {
+		return err
+	}
+	return c.setRemote(key, value, ttl)
+}
+
+// setRemote записывает значение в memcached независимо от режима
+func (c *Cache) setRemote(key string, value []byte, ttl time.Duration) error {
+	prefix := c.prefix + ":" + key
+	item, chunks, err := c.split(prefix, value, ttl)
+	if err != nil {
+		return err
+	}
+	// Части прежнего значения ищем, только когда и новое разбито: иначе каждая маленькая запись
+	// стоила бы лишнего чтения. Части манифеста, перезаписанного маленьким значением, истекут с TTL.
+	var stale []string
+	if len(chunks) > 0 {
+		stale = c.chunkKeys(opSet, prefix)
+	}
+	start := time.Now()
+	err = c.client.Set(item)
+	observe(opSet, start, err)
+	if err != nil {
+		c.deleteChunks(chunks)
+		return err
+	}
+	c.deleteChunks(stale)
+	return nil
+}
+
+// Add сохраняет значение, только если ключа ещё нет; иначе возвращает memcache.ErrNotStored
+func (c *Cache) Add(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	var err error
+	if c.inLocal(func(local *memoryCache) { err = local.add(key, value, ttl) }) {
+		return err
 	}
 	prefix := c.prefix + ":" + key
-	err := c.client.Set(&memcache.Item{
-		Key:        prefix,
-		Value:      value,
-		Expiration: int32(ttl.Seconds()),
-	})
+	item, chunks, err := c.split(prefix, value, ttl)
+	if err != nil {
+		return err
+	}
+	start := time.Now()
+	err = c.client.Add(item)
+	observe(opAdd, start, err)
+	if err != nil {
+		c.deleteChunks(chunks)
+	}
+	return err
+}
+
+// CompareAndSwap читает значение и записывает результат update, только если ключ
+// не изменился с момента чтения. При гонке возвращает memcache.ErrCASConflict —
+// повторять попытку решает вызывающий; для отсутствующего ключа — memcache.ErrCacheMiss.
+func (c *Cache) CompareAndSwap(ctx context.Context, key string, ttl time.Duration, update func(current []byte) ([]byte, error)) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	var err error
+	if c.inLocal(func(local *memoryCache) { err = local.compareAndSwap(key, ttl, update) }) {
+		return err
+	}
+	prefix := c.prefix + ":" + key
+	start := time.Now()
+	item, err := c.client.Get(prefix)
+	if err != nil {
+		observe(opCAS, start, err)
+		lookup(opCAS, err)
+		return err
+	}
+	current, err := c.assemble(item)
+	lookup(opCAS, err)
+	if err != nil {
+		return err
+	}
+	value, err := update(current)
+	if err != nil {
+		return err
+	}
+	stale := c.manifestKeys(item)
+	next, chunks, err := c.split(prefix, value, ttl)
+	if err != nil {
+		return err
+	}
+	item.Value, item.Flags, item.Expiration = next.Value, next.Flags, next.Expiration
+	err = c.client.CompareAndSwap(item)
+	observe(opCAS, start, err)
+	if err != nil {
+		c.deleteChunks(chunks)
+		return err
+	}
+	c.deleteChunks(stale)
+	return nil
+}
+
+// Touch продлевает срок жизни ключа; для разбитого на части значения — и всех частей
+func (c *Cache) Touch(ctx context.Context, key string, ttl time.Duration) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	var err error
+	if c.inLocal(func(local *memoryCache) { err = local.touch(key, ttl) }) {
+		return err
+	}
+	prefix := c.prefix + ":" + key
+	start := time.Now()
+	for _, chunk := range c.chunkKeys(opTouch, prefix) {
+		if err := c.client.Touch(chunk, expiration(ttl)); err != nil {
+			observe(opTouch, start, err)
+			return err
+		}
+	}
+	err = c.client.Touch(prefix, expiration(ttl))
+	observe(opTouch, start, err)
+	return err
+}
+
+// Delete удаляет ключ; для отсутствующего ключа возвращает memcache.ErrCacheMiss
+func (c *Cache) Delete(ctx context.Context, key string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	var err error
+	if c.inLocal(func(local *memoryCache) { err = local.delete(key) }) {
+		return err
+	}
+	prefix := c.prefix + ":" + key
+	chunks := c.chunkKeys(opDelete, prefix)
+	start := time.Now()
+	err = c.client.Delete(prefix)
+	observe(opDelete, start, err)
+	c.deleteChunks(chunks)
 	return err
 }
 
 func (c *Cache) Close() error {
+	if c.codec != nil {
+		c.codec.close()
+	}
+	if c.client == nil {
+		return nil
+	}
 	return c.client.Close()
 }
 
+// IsHealthy проверяет связь с memcached независимо от режима деградации
 func (c *Cache) IsHealthy(ctx context.Context) bool {
 	if err := ctx.Err(); err != nil {
 		return false
@@ -93,3 +390,125 @@ func (c *Cache) IsHealthy(ctx context.Context) bool {
 
 	return true
 }
+
+// Degraded сообщает, что memcached недоступен и кэш работает в памяти процесса
+func (c *Cache) Degraded() bool {
+	return c.degraded.Load()
+}
+
+// Run проверяет memcached раз в health_interval и переключает режим: при потере связи — на память
+// процесса, при восстановлении — обратно, перенося накопленные за время деградации значения
+// в memcached. Возвращается после отмены ctx.
+func (c *Cache) Run(ctx context.Context) {
+	if !c.enable || c.client == nil {
+		return
+	}
+	ticker := time.NewTicker(c.healthInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		healthy := c.IsHealthy(ctx)
+		switch {
+		case healthy && c.degraded.Load():
+			c.recover(ctx)
+		case !healthy && !c.degraded.Load() && ctx.Err() == nil:
+			slog.Warn("memcached is unreachable, serving cache from process memory")
+			c.setDegraded(true)
+		}
+	}
+}
+
+// recover возвращает кэш к memcached. Значения, записанные за время деградации, переносятся
+// в memcached с оставшимся сроком жизни, чтобы операции, созданные без memcached, не потерялись.
+// Перенос идёт под mode.Lock до снятия degraded: обращения к кэшу ждут его окончания, поэтому
+// никто не читает из memcached ещё не перенесённые ключи и не пишет туда раньше переноса.
+// Если memcached снова отказал, записи возвращаются в память и кэш остаётся в деградации.
+func (c *Cache) recover(ctx context.Context) {
+	c.mode.Lock()
+	items := c.local.drain()
+	now := time.Now()
+	var err error
+	for key, item := range items {
+		if err = ctx.Err(); err != nil {
+			break
+		}
+		var ttl time.Duration
+		if !item.expiresAt.IsZero() {
+			// Округляем вверх: memcached считает срок в секундах, а 0 означает «без срока»
+			ttl = max(item.expiresAt.Sub(now).Truncate(time.Second)+time.Second, time.Second)
+		}
+		if err = c.setRemote(key, item.value, ttl); err != nil {
+			break
+		}
+	}
+	if err != nil {
+		c.local.restore(items)
+		c.mode.Unlock()
+		slog.Warn("failed to move cached values to memcached, staying in process memory", "total", len(items), "err", err)
+		return
+	}
+	c.degraded.Store(false)
+	c.mode.Unlock()
+	metrics.UpdateCacheDegraded(false)
+	slog.Info("memcached is reachable again", "moved", len(items))
+}
+
+func (c *Cache) setDegraded(degraded bool) {
+	c.mode.Lock()
+	c.degraded.Store(degraded)
+	c.mode.Unlock()
+	metrics.UpdateCacheDegraded(degraded)
+}
+
+// inLocal выполняет fn над кэшем в памяти процесса, если memcached выключен или кэш в режиме
+// деградации, и сообщает об этом
+func (c *Cache) inLocal(fn func(local *memoryCache)) bool {
+	c.mode.RLock()
+	defer c.mode.RUnlock()
+	if c.enable && !c.degraded.Load() {
+		return false
+	}
+	fn(c.local)
+	return true
+}
+
+// observe учитывает обращение к серверу: время и исход. Промах, отказ Add для существующего ключа
+// и конфликт CAS — штатные исходы, поэтому в cache_errors попадают только сбои связи и ответы
+// сервера об ошибке: так «холодный» кэш отличается от недоступного memcached.
+func observe(operation string, start time.Time, err error) {
+	metrics.UpdateCacheOperationTime(operation, time.Since(start).Seconds())
+	result := resultOK
+	switch {
+	case err == nil:
+	case errors.Is(err, memcache.ErrCacheMiss):
+		result = resultMiss
+	case errors.Is(err, memcache.ErrNotStored):
+		result = resultNotStored
+	case errors.Is(err, memcache.ErrCASConflict):
+		result = resultConflict
+	default:
+		result = resultError
+		metrics.UpdateCacheErrors(operation)
+	}
+	metrics.UpdateCacheRequest(operation, result)
+}
+
+// lookup учитывает попадание или промах чтения; записи в hit ratio не входят.
+// Промахом считается и значение, у которого не нашлась часть.
+func lookup(operation string, err error) {
+	switch {
+	case err == nil:
+		metrics.UpdateCacheHits(operation)
+	case errors.Is(err, memcache.ErrCacheMiss):
+		metrics.UpdateCacheMisses(operation)
+	}
+}
+
+func expiration(ttl time.Duration) int32 {
+	return int32(ttl.Seconds())
+}
@@ -0,0 +1,182 @@
+package memecached
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Caritas-Team/reviewer/internal/config"
+	"github.com/Caritas-Team/reviewer/internal/memecached/memecachedtest"
+	"github.com/Caritas-Team/reviewer/internal/metrics/metricstest"
+)
+
+func newTestCache(t *testing.T, f *memecachedtest.Server, chunkSize int) *Cache {
+	t.Helper()
+	c, err := NewCache(context.Background(), config.Config{Memcached: config.Memcached{
+		Enable:          true,
+		Servers:         []string{f.Addr()},
+		KeyPrefix:       "test",
+		ChunkSize:       chunkSize,
+		ConnectAttempts: 1,
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = c.Close() })
+	if c.Degraded() {
+		t.Fatal("cache is degraded with a reachable server")
+	}
+	return c
+}
+
+func TestCacheMetricsCountReadsOnly(t *testing.T) {
+	registry := metricstest.UseRegistry(t)
+	c := newTestCache(t, memecachedtest.Start(t), 0)
+	ctx := context.Background()
+
+	if err := c.Set(ctx, "a", []byte("1"), time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Add(ctx, "a", []byte("2"), time.Minute); err == nil {
+		t.Fatal("Add of an existing key succeeded")
+	}
+	if _, err := c.Get(ctx, "a"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Get(ctx, "missing"); err == nil {
+		t.Fatal("Get of a missing key succeeded")
+	}
+	values, err := c.GetMulti(ctx, []string{"x", "y"})
+	if err != nil || len(values) != 0 {
+		t.Fatalf("GetMulti = %v, %v; want no values", values, err)
+	}
+	if _, err := c.GetMulti(ctx, []string{"a", "z"}); err != nil {
+		t.Fatal(err)
+	}
+
+	hits := metricstest.Counters(t, registry, "cache_hits")
+	misses := metricstest.Counters(t, registry, "cache_misses")
+	wantHits := map[string]float64{"operation=get": 1, "operation=get_multi": 1}
+	wantMisses := map[string]float64{"operation=get": 1, "operation=get_multi": 3}
+	for series, want := range wantHits {
+		if hits[series] != want {
+			t.Errorf("cache_hits{%s} = %v, want %v", series, hits[series], want)
+		}
+	}
+	for series, want := range wantMisses {
+		if misses[series] != want {
+			t.Errorf("cache_misses{%s} = %v, want %v", series, misses[series], want)
+		}
+	}
+	for _, op := range []string{opSet, opAdd} {
+		if hits["operation="+op]+misses["operation="+op] != 0 {
+			t.Errorf("write %s counted as a read: hits %v, misses %v", op, hits, misses)
+		}
+	}
+
+	requests := metricstest.Counters(t, registry, "cache_requests_total")
+	for series, want := range map[string]float64{
+		"operation=set,result=ok":         1,
+		"operation=add,result=not_stored": 1,
+		"operation=get,result=miss":       1,
+	} {
+		if requests[series] != want {
+			t.Errorf("cache_requests_total{%s} = %v, want %v (all: %v)", series, requests[series], want, requests)
+		}
+	}
+	if errs := metricstest.Counters(t, registry, "cache_errors"); len(errs) != 0 {
+		t.Errorf("cache_errors = %v, want none", errs)
+	}
+}
+
+func TestRecoverBlocksWritesUntilMoved(t *testing.T) {
+	f := memecachedtest.Start(t)
+	c := newTestCache(t, f, 0)
+	ctx := context.Background()
+
+	c.setDegraded(true)
+	if err := c.Set(ctx, "op", []byte("PROGRESS"), time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Set(ctx, "other", []byte("1"), time.Minute); err != nil {
+		t.Fatal(err)
+	}
+
+	// Первая запись переноса ждёт, пока тест не начнёт конкурирующую запись и чтение
+	moving := make(chan struct{})
+	release := make(chan struct{})
+	var once sync.Once
+	f.OnStore(func(string) {
+		once.Do(func() {
+			close(moving)
+			<-release
+		})
+	})
+
+	recovered := make(chan struct{})
+	go func() {
+		c.recover(ctx)
+		close(recovered)
+	}()
+	<-moving
+
+	written := make(chan error, 1)
+	go func() { written <- c.Set(ctx, "op", []byte("DONE"), time.Minute) }()
+	read := make(chan error, 2)
+	go func() {
+		_, err := c.Get(ctx, "other")
+		read <- err
+	}()
+	go func() {
+		_, err := c.Get(ctx, "op")
+		read <- err
+	}()
+	select {
+	case err := <-written:
+		t.Fatalf("write finished during recovery: %v", err)
+	case <-time.After(20 * time.Millisecond):
+	}
+	close(release)
+
+	<-recovered
+	if err := <-written; err != nil {
+		t.Fatal(err)
+	}
+	for range 2 {
+		if err := <-read; err != nil {
+			t.Errorf("Get during recovery: %v", err)
+		}
+	}
+	if c.Degraded() {
+		t.Fatal("cache is still degraded")
+	}
+	got, err := c.Get(ctx, "op")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != "DONE" {
+		t.Errorf("op = %q, want the value written during recovery", got)
+	}
+}
+
+func TestRecoverKeepsValuesWhenMemcachedFails(t *testing.T) {
+	f := memecachedtest.Start(t)
+	c := newTestCache(t, f, 0)
+	ctx := context.Background()
+
+	c.setDegraded(true)
+	if err := c.Set(ctx, "op", []byte("PROGRESS"), time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	f.Refuse(true)
+	c.recover(ctx)
+
+	if !c.Degraded() {
+		t.Fatal("cache left degraded mode without moving values")
+	}
+	got, err := c.Get(ctx, "op")
+	if err != nil || string(got) != "PROGRESS" {
+		t.Errorf("Get = %q, %v; want the value kept in memory", got, err)
+	}
+}
@@ -0,0 +1,221 @@
+// Package memecachedtest содержит поддельный memcached для тестов кэша и его пользователей
+package memecachedtest
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// Server — минимальный memcached по текстовому протоколу: version, get/gets, set/add/replace/cas,
+// delete и touch. Сроки жизни не соблюдает — тестам кэша они не нужны.
+type Server struct {
+	mu      sync.Mutex
+	items   map[string]*item
+	cas     uint64
+	ln      net.Listener
+	refuse  bool
+	reads   int
+	onStore func(key string)
+}
+
+type item struct {
+	value []byte
+	flags string
+	cas   uint64
+}
+
+// Start запускает сервер на свободном локальном порту и останавливает его в конце теста
+func Start(t testing.TB) *Server {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &Server{items: make(map[string]*item), ln: ln}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	t.Cleanup(func() { _ = ln.Close() })
+	return s
+}
+
+// Addr возвращает адрес сервера для memcached.servers
+func (s *Server) Addr() string {
+	return s.ln.Addr().String()
+}
+
+// Keys возвращает ключи, хранящиеся на сервере
+func (s *Server) Keys() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	keys := make([]string, 0, len(s.items))
+	for key := range s.items {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// Reads возвращает число выполненных команд get/gets
+func (s *Server) Reads() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.reads
+}
+
+// Refuse включает ответ ошибкой сервера на все записи
+func (s *Server) Refuse(refuse bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.refuse = refuse
+}
+
+// OnStore задаёт функцию, которую сервер вызывает перед каждой записью (set, add, replace, cas)
+// без своей блокировки: из неё можно обращаться к серверу другим клиентом
+func (s *Server) OnStore(fn func(key string)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.onStore = fn
+}
+
+// Update заменяет значение ключа так, как это сделал бы другой клиент: CAS-токен меняется
+func (s *Server) Update(key string, update func(value []byte) []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if it, ok := s.items[key]; ok {
+		s.cas++
+		it.value, it.cas = update(it.value), s.cas
+	}
+}
+
+// Delete удаляет ключ, как если бы memcached его вытеснил
+func (s *Server) Delete(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.items, key)
+}
+
+func (s *Server) serve(conn net.Conn) {
+	defer func() { _ = conn.Close() }()
+	r := bufio.NewReader(conn)
+	w := bufio.NewWriter(conn)
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		var data []byte
+		switch fields[0] {
+		case "set", "add", "replace", "cas":
+			n, _ := strconv.Atoi(fields[4])
+			data = make([]byte, n+2)
+			if _, err := io.ReadFull(r, data); err != nil {
+				return
+			}
+			data = data[:n]
+			s.mu.Lock()
+			onStore := s.onStore
+			s.mu.Unlock()
+			if onStore != nil {
+				onStore(fields[1])
+			}
+		}
+		s.handle(w, fields, data)
+		if err := w.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+func (s *Server) handle(w *bufio.Writer, fields []string, data []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	reply := func(line string) { _, _ = w.WriteString(line + "\r\n") }
+	key := ""
+	if len(fields) > 1 {
+		key = fields[1]
+	}
+	current, exists := s.items[key]
+	store := func() {
+		s.cas++
+		s.items[key] = &item{value: data, flags: fields[2], cas: s.cas}
+		reply("STORED")
+	}
+
+	switch fields[0] {
+	case "set", "add", "replace", "cas":
+		if s.refuse {
+			reply("SERVER_ERROR out of memory storing object")
+			return
+		}
+	}
+	switch fields[0] {
+	case "version":
+		reply("VERSION 1.6.0")
+	case "get", "gets":
+		s.reads++
+		for _, k := range fields[1:] {
+			if it, ok := s.items[k]; ok {
+				_, _ = fmt.Fprintf(w, "VALUE %s %s %d %d\r\n", k, it.flags, len(it.value), it.cas)
+				_, _ = w.Write(it.value)
+				reply("")
+			}
+		}
+		reply("END")
+	case "set":
+		store()
+	case "add":
+		if exists {
+			reply("NOT_STORED")
+			return
+		}
+		store()
+	case "replace":
+		if !exists {
+			reply("NOT_STORED")
+			return
+		}
+		store()
+	case "cas":
+		id, _ := strconv.ParseUint(fields[5], 10, 64)
+		switch {
+		case !exists:
+			reply("NOT_FOUND")
+		case current.cas != id:
+			reply("EXISTS")
+		default:
+			store()
+		}
+	case "delete":
+		if !exists {
+			reply("NOT_FOUND")
+			return
+		}
+		delete(s.items, key)
+		reply("DELETED")
+	case "touch":
+		if !exists {
+			reply("NOT_FOUND")
+			return
+		}
+		reply("TOUCHED")
+	default:
+		reply("ERROR")
+	}
+}
@@ -0,0 +1,165 @@
+package memecached
+
+import (
+	"bytes"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/bradfitz/gomemcache/memcache"
+)
+
+// memoryMaxItems ограничивает кэш в памяти процесса
+const memoryMaxItems = 100000
+
+// ErrMemoryFull — в памяти процесса нет места: живые записи не вытесняются, потому что
+// без memcached это единственная копия операций и ключей идемпотентности
+var ErrMemoryFull = errors.New("in-memory cache is full")
+
+type memoryItem struct {
+	value     []byte
+	expiresAt time.Time
+}
+
+func (i memoryItem) expired(now time.Time) bool {
+	return !i.expiresAt.IsZero() && now.After(i.expiresAt)
+}
+
+// memoryCache подменяет memcached, когда тот выключен или недоступен. Ошибки те же, что у memcached:
+// memcache.ErrCacheMiss для отсутствующего ключа и memcache.ErrNotStored для Add существующего;
+// новая запись при memoryMaxItems живых записей отклоняется с ErrMemoryFull.
+type memoryCache struct {
+	mu    sync.Mutex
+	items map[string]memoryItem
+}
+
+func newMemoryCache() *memoryCache {
+	return &memoryCache{items: make(map[string]memoryItem)}
+}
+
+func (m *memoryCache) get(key string) ([]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.lookup(key, time.Now())
+}
+
+func (m *memoryCache) set(key string, value []byte, ttl time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.store(key, value, ttl, time.Now())
+}
+
+func (m *memoryCache) add(key string, value []byte, ttl time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	if _, err := m.lookup(key, now); err == nil {
+		return memcache.ErrNotStored
+	}
+	return m.store(key, value, ttl, now)
+}
+
+// compareAndSwap выполняет update под блокировкой, поэтому конфликтов, в отличие от memcached, не бывает
+func (m *memoryCache) compareAndSwap(key string, ttl time.Duration, update func(current []byte) ([]byte, error)) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	current, err := m.lookup(key, now)
+	if err != nil {
+		return err
+	}
+	value, err := update(current)
+	if err != nil {
+		return err
+	}
+	return m.store(key, value, ttl, now)
+}
+
+func (m *memoryCache) touch(key string, ttl time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	item, ok := m.items[key]
+	if !ok || item.expired(now) {
+		return memcache.ErrCacheMiss
+	}
+	item.expiresAt = expiresAt(ttl, now)
+	m.items[key] = item
+	return nil
+}
+
+func (m *memoryCache) delete(key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.items[key]; !ok {
+		return memcache.ErrCacheMiss
+	}
+	delete(m.items, key)
+	return nil
+}
+
+// drain забирает все живые записи с оставшимся временем жизни и очищает кэш
+func (m *memoryCache) drain() map[string]memoryItem {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	items := make(map[string]memoryItem, len(m.items))
+	for key, item := range m.items {
+		if !item.expired(now) {
+			items[key] = item
+		}
+	}
+	m.items = make(map[string]memoryItem)
+	return items
+}
+
+// restore возвращает записи, забранные drain; записи, сделанные после drain, не перезаписываются
+func (m *memoryCache) restore(items map[string]memoryItem) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for key, item := range items {
+		if _, ok := m.items[key]; !ok {
+			m.items[key] = item
+		}
+	}
+}
+
+func (m *memoryCache) lookup(key string, now time.Time) ([]byte, error) {
+	item, ok := m.items[key]
+	if !ok {
+		return nil, memcache.ErrCacheMiss
+	}
+	if item.expired(now) {
+		delete(m.items, key)
+		return nil, memcache.ErrCacheMiss
+	}
+	return bytes.Clone(item.value), nil
+}
+
+func (m *memoryCache) store(key string, value []byte, ttl time.Duration, now time.Time) error {
+	if _, ok := m.items[key]; !ok && len(m.items) >= memoryMaxItems {
+		m.sweep(now)
+		if len(m.items) >= memoryMaxItems {
+			return ErrMemoryFull
+		}
+	}
+	m.items[key] = memoryItem{value: bytes.Clone(value), expiresAt: expiresAt(ttl, now)}
+	return nil
+}
+
+// sweep удаляет просроченные записи; живые не трогает
+func (m *memoryCache) sweep(now time.Time) {
+	for key, item := range m.items {
+		if item.expired(now) {
+			delete(m.items, key)
+		}
+	}
+}
+
+// expiresAt переводит ttl в момент истечения; ttl <= 0 — без срока, как в memcached
+func expiresAt(ttl time.Duration, now time.Time) time.Time {
+	if ttl <= 0 {
+		return time.Time{}
+	}
+	return now.Add(ttl)
+}
@@ -0,0 +1,40 @@
+package memecached
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestMemoryCacheFullKeepsLiveEntries(t *testing.T) {
+	m := newMemoryCache()
+	for i := range memoryMaxItems {
+		if err := m.set(strconv.Itoa(i), []byte("v"), time.Minute); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := m.set("new", []byte("v"), time.Minute); !errors.Is(err, ErrMemoryFull) {
+		t.Fatalf("set on a full cache: err = %v, want %v", err, ErrMemoryFull)
+	}
+	if err := m.add("new", []byte("v"), time.Minute); !errors.Is(err, ErrMemoryFull) {
+		t.Fatalf("add on a full cache: err = %v, want %v", err, ErrMemoryFull)
+	}
+	if err := m.set("0", []byte("w"), time.Minute); err != nil {
+		t.Fatalf("overwrite on a full cache: %v", err)
+	}
+	for _, key := range []string{"0", "1", strconv.Itoa(memoryMaxItems - 1)} {
+		if _, err := m.get(key); err != nil {
+			t.Errorf("live entry %q is gone: %v", key, err)
+		}
+	}
+
+	// Просроченные записи освобождают место
+	m.mu.Lock()
+	m.items["1"] = memoryItem{value: []byte("v"), expiresAt: time.Now().Add(-time.Second)}
+	m.mu.Unlock()
+	if err := m.set("new", []byte("v"), time.Minute); err != nil {
+		t.Fatalf("set after an entry expired: %v", err)
+	}
+}
@@ -0,0 +1,191 @@
+package metrics
+
+import (
+	"net/http"
+	"sync/atomic"
+)
+
+var defaultRegistry atomic.Pointer[Registry]
+
+func init() {
+	r, err := NewRegistry(Options{})
+	if err != nil {
+		panic(err)
+	}
+	defaultRegistry.Store(r)
+}
+
+// Default возвращает реестр, в который пишут функции пакета Update*
+func Default() *Registry {
+	return defaultRegistry.Load()
+}
+
+// SetDefault заменяет реестр по умолчанию; вызывать при старте, до начала записи метрик
+func SetDefault(r *Registry) {
+	defaultRegistry.Store(r)
+}
+
+// Handler отдаёт метрики реестра по умолчанию
+func Handler() http.Handler {
+	return Default().Handler()
+}
+
+// UpdateFileProcessingTime обновляет время обработки файла
+func UpdateFileProcessingTime(result string, duration float64) {
+	Default().UpdateFileProcessingTime(result, duration)
+}
+
+// UpdateFileSize обновляет размер загруженного файла
+func UpdateFileSize(size float64) {
+	Default().UpdateFileSize(size)
+}
+
+// UpdateFileUploadSuccess увеличивает счётчик успешных загрузок файлов
+func UpdateFileUploadSuccess() {
+	Default().UpdateFileUploadSuccess()
+}
+
+// UpdateFileUploadError увеличивает счётчик ошибок при загрузке файлов
+func UpdateFileUploadError() {
+	Default().UpdateFileUploadError()
+}
+
+// UpdateCurrentFilesInProgress обновляет текущее количество файлов в процессе обработки
+func UpdateCurrentFilesInProgress(count float64) {
+	Default().UpdateCurrentFilesInProgress(count)
+}
+
+// UpdateOperationsPerSecond увеличивает счётчик операций в секунду
+func UpdateOperationsPerSecond() {
+	Default().UpdateOperationsPerSecond()
+}
+
+// UpdateQueueLength обновляет длину очереди задач
+func UpdateQueueLength(length float64) {
+	Default().UpdateQueueLength(length)
+}
+
+// UpdateWorkerQueueDelay обновляет задержку между постановкой задачи в очередь и началом её обработки
+func UpdateWorkerQueueDelay(delay float64) {
+	Default().UpdateWorkerQueueDelay(delay)
+}
+
+// UpdateServerUptime обновляет время работы сервера
+func UpdateServerUptime(seconds float64) {
+	Default().UpdateServerUptime(seconds)
+}
+
+// UpdateMemoryUsage обновляет использование оперативной памяти
+func UpdateMemoryUsage(bytes float64) {
+	Default().UpdateMemoryUsage(bytes)
+}
+
+// UpdateCPULoadAverage обновляет среднюю загрузку ЦПУ
+func UpdateCPULoadAverage(load float64) {
+	Default().UpdateCPULoadAverage(load)
+}
+
+// UpdateRetryAttempts увеличивает счётчик попыток повторной обработки
+func UpdateRetryAttempts() {
+	Default().UpdateRetryAttempts()
+}
+
+// UpdateOperationStatus увеличивает счётчик статусов операций
+func UpdateOperationStatus(status string) {
+	Default().UpdateOperationStatus(status)
+}
+
+// UpdateCacheHits увеличивает счётчик найденных при чтении ключей
+func UpdateCacheHits(operation string) {
+	Default().UpdateCacheHits(operation)
+}
+
+// UpdateCacheMisses увеличивает счётчик ненайденных при чтении ключей
+func UpdateCacheMisses(operation string) {
+	Default().UpdateCacheMisses(operation)
+}
+
+// UpdateCacheErrors увеличивает счётчик ошибок Memcached
+func UpdateCacheErrors(operation string) {
+	Default().UpdateCacheErrors(operation)
+}
+
+// UpdateCacheRequest учитывает обращение к Memcached с его исходом
+func UpdateCacheRequest(operation, result string) {
+	Default().UpdateCacheRequest(operation, result)
+}
+
+// UpdateCacheOperationTime обновляет время выполнения операции с Memcached
+func UpdateCacheOperationTime(operation string, duration float64) {
+	Default().UpdateCacheOperationTime(operation, duration)
+}
+
+// UpdateCacheDegraded отмечает, работает ли кэш в памяти процесса вместо Memcached
+func UpdateCacheDegraded(degraded bool) {
+	Default().UpdateCacheDegraded(degraded)
+}
+
+// UpdateRateLimitExceeded увеличивает счётчик превышений лимита запросов
+func UpdateRateLimitExceeded() {
+	Default().UpdateRateLimitExceeded()
+}
+
+// UpdateRequestCountByIP увеличивает счётчик запросов от каждого IP-адреса
+func UpdateRequestCountByIP(ip string) {
+	Default().UpdateRequestCountByIP(ip)
+}
+
+// RemoveRequestCountByIP удаляет серию IP-адреса, вытесненного из учитываемых по отдельности
+func RemoveRequestCountByIP(ip string) {
+	Default().RemoveRequestCountByIP(ip)
+}
+
+// UpdateHTTPRequest учитывает обработанный HTTP-запрос: количество, длительность и размер ответа
+func UpdateHTTPRequest(route, method, code string, duration, size float64) {
+	Default().UpdateHTTPRequest(route, method, code, duration, size)
+}
+
+// UpdateDataExtractionSuccess увеличивает счётчик успешных извлечений данных из PDF-файлов
+func UpdateDataExtractionSuccess() {
+	Default().UpdateDataExtractionSuccess()
+}
+
+// UpdateDataExtractionError увеличивает счётчик ошибок при извлечении данных
+func UpdateDataExtractionError() {
+	Default().UpdateDataExtractionError()
+}
+
+// UpdateDataExtractionTime обновляет время извлечения данных из одного PDF-файла
+func UpdateDataExtractionTime(duration float64) {
+	Default().UpdateDataExtractionTime(duration)
+}
+
+// UpdateComparisonSuccess увеличивает счётчик успешных сравнений "до/после"
+func UpdateComparisonSuccess() {
+	Default().UpdateComparisonSuccess()
+}
+
+// UpdateComparisonError увеличивает счётчик ошибок при сравнении "до/после"
+func UpdateComparisonError() {
+	Default().UpdateComparisonError()
+}
+
+// UpdateComparisonTime обновляет время выполнения сравнения "до/после"
+func UpdateComparisonTime(duration float64) {
+	Default().UpdateComparisonTime(duration)
+}
+
+// UpdateExportSuccess увеличивает счётчик успешных экспортов отчётов
+func UpdateExportSuccess() {
+	Default().UpdateExportSuccess()
+}
+
+// UpdateExportError увеличивает счётчик ошибок при экспорте отчётов
+func UpdateExportError() {
+	Default().UpdateExportError()
+}
+
+// UpdateExportTime обновляет время выполнения экспорта отчётов
+func UpdateExportTime(duration float64) {
+	Default().UpdateExportTime(duration)
+}
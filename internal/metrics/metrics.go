@@ -1,337 +1,520 @@
 package metrics
 
 import (
+	"fmt"
 	"net/http"
-	"time"
+	"slices"
 
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
 	"github.com/prometheus/client_golang/prometheus/promauto"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
+// Namespace — общий префикс всех метрик сервиса
+const Namespace = "pdf_service"
+
 var (
+	// DefaultDurationBuckets — границы гистограмм *_seconds: от HTTP-запросов до обработки файла
+	DefaultDurationBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60}
+
+	// DefaultSizeBuckets — границы гистограмм *_bytes: от 1 КБ до 64 МБ
+	DefaultSizeBuckets = prometheus.ExponentialBuckets(1024, 4, 9)
+)
+
+// Options — настройки реестра; пустые границы заменяются значениями по умолчанию
+type Options struct {
+	DurationBuckets []float64
+	SizeBuckets     []float64
+}
+
+// Registry — набор метрик сервиса в собственном prometheus.Registry.
+// Отдельный экземпляр позволяет тестам проверять значения без глобального состояния.
+type Registry struct {
+	registry *prometheus.Registry
+
+	fileProcessingTimeSeconds  *prometheus.HistogramVec
+	fileSizeBytes              prometheus.Histogram
+	fileUploadSuccessCount     prometheus.Counter
+	fileUploadErrorCount       prometheus.Counter
+	currentFilesInProgress     prometheus.Gauge
+	operationsPerSecond        prometheus.Counter
+	queueLength                prometheus.Gauge
+	workerQueueDelaySeconds    prometheus.Histogram
+	serverUptimeSeconds        prometheus.Gauge
+	memoryUsageBytes           prometheus.Gauge
+	cpuLoadAverage             prometheus.Gauge
+	retryAttempts              prometheus.Counter
+	operationStatusCounts      *prometheus.CounterVec
+	cacheHits                  *prometheus.CounterVec
+	cacheMisses                *prometheus.CounterVec
+	cacheErrors                *prometheus.CounterVec
+	cacheRequestsTotal         *prometheus.CounterVec
+	cacheOperationSeconds      *prometheus.HistogramVec
+	cacheDegraded              prometheus.Gauge
+	rateLimitExceededCount     prometheus.Counter
+	requestCountByIP           *prometheus.CounterVec
+	httpRequestsTotal          *prometheus.CounterVec
+	httpRequestDurationSeconds *prometheus.HistogramVec
+	httpResponseSizeBytes      *prometheus.HistogramVec
+	dataExtractionSuccessCount prometheus.Counter
+	dataExtractionErrorCount   prometheus.Counter
+	dataExtractionTimeSeconds  prometheus.Histogram
+	comparisonSuccessCount     prometheus.Counter
+	comparisonErrorCount       prometheus.Counter
+	comparisonTimeSeconds      prometheus.Histogram
+	exportSuccessCount         prometheus.Counter
+	exportErrorCount           prometheus.Counter
+	exportTimeSeconds          prometheus.Histogram
+}
+
+// NewRegistry создаёт реестр со всеми метриками сервиса, а также метриками Go-рантайма и процесса
+func NewRegistry(opts Options) (*Registry, error) {
+	if len(opts.DurationBuckets) == 0 {
+		opts.DurationBuckets = DefaultDurationBuckets
+	}
+	if len(opts.SizeBuckets) == 0 {
+		opts.SizeBuckets = DefaultSizeBuckets
+	}
+	if err := validateBuckets(opts.DurationBuckets); err != nil {
+		return nil, fmt.Errorf("duration buckets: %w", err)
+	}
+	if err := validateBuckets(opts.SizeBuckets); err != nil {
+		return nil, fmt.Errorf("size buckets: %w", err)
+	}
+
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+
+	r := &Registry{registry: reg}
+	f := promauto.With(reg)
+
 	// Время обработки одного PDF-файла (в секундах)
-	fileProcessingTimeSeconds = promauto.NewSummaryVec(prometheus.SummaryOpts{
-		Namespace: "pdf_service",
+	r.fileProcessingTimeSeconds = f.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: Namespace,
 		Name:      "file_processing_time_seconds",
 		Help:      "Время обработки одного PDF-файла (в секундах)",
+		Buckets:   opts.DurationBuckets,
 	}, []string{"result"})
 
 	// Размер загруженных файлов (в байтах)
-	fileSizeBytes = promauto.NewSummary(prometheus.SummaryOpts{
-		Namespace: "calculator_service",
+	r.fileSizeBytes = f.NewHistogram(prometheus.HistogramOpts{
+		Namespace: Namespace,
 		Name:      "file_size_bytes",
 		Help:      "Размер загруженных файлов (в байтах)",
+		Buckets:   opts.SizeBuckets,
 	})
 
 	// Количество успешно загруженных файлов
-	fileUploadSuccessCount = promauto.NewCounter(prometheus.CounterOpts{
-		Namespace: "pdf_service",
+	r.fileUploadSuccessCount = f.NewCounter(prometheus.CounterOpts{
+		Namespace: Namespace,
 		Name:      "file_upload_success_count",
 		Help:      "Количество успешно загруженных файлов",
 	})
 
 	// Количество ошибок при загрузке файлов
-	fileUploadErrorCount = promauto.NewCounter(prometheus.CounterOpts{
-		Namespace: "pdf_service",
+	r.fileUploadErrorCount = f.NewCounter(prometheus.CounterOpts{
+		Namespace: Namespace,
 		Name:      "file_upload_error_count",
 		Help:      "Количество ошибок при загрузке файлов",
 	})
 
 	// Текущее количество файлов, находящихся в процессе обработки
-	currentFilesInProgress = promauto.NewGauge(prometheus.GaugeOpts{
-		Namespace: "pdf_service",
+	r.currentFilesInProgress = f.NewGauge(prometheus.GaugeOpts{
+		Namespace: Namespace,
 		Name:      "current_files_in_progress",
 		Help:      "Текущее количество файлов, находящихся в процессе обработки",
 	})
 
 	// Количество операций в секунду
-	operationsPerSecond = promauto.NewCounter(prometheus.CounterOpts{
-		Namespace: "pdf_service",
+	r.operationsPerSecond = f.NewCounter(prometheus.CounterOpts{
+		Namespace: Namespace,
 		Name:      "operations_per_second",
 		Help:      "Количество операций в секунду",
 	})
 
-	// Длина очереди задач, ожидающих обработки.
-	queueLength = promauto.NewGauge(prometheus.GaugeOpts{
-		Namespace: "pdf_service",
+	// Длина очереди задач, ожидающих обработки
+	r.queueLength = f.NewGauge(prometheus.GaugeOpts{
+		Namespace: Namespace,
 		Name:      "queue_length",
 		Help:      "Длина очереди задач, ожидающих обработки",
 	})
 
 	// Задержка между постановкой задачи в очередь и началом её обработки (в секундах)
-	workerQueueDelaySeconds = promauto.NewSummary(prometheus.SummaryOpts{
-		Namespace: "pdf_service",
+	r.workerQueueDelaySeconds = f.NewHistogram(prometheus.HistogramOpts{
+		Namespace: Namespace,
 		Name:      "worker_queue_delay_seconds",
 		Help:      "Задержка между постановкой задачи в очередь и началом её обработки (в секундах)",
+		Buckets:   opts.DurationBuckets,
 	})
 
 	// Время работы сервера (в секундах)
-	serverUptimeSeconds = promauto.NewGauge(prometheus.GaugeOpts{
-		Namespace: "pdf_service",
+	r.serverUptimeSeconds = f.NewGauge(prometheus.GaugeOpts{
+		Namespace: Namespace,
 		Name:      "server_uptime_seconds",
 		Help:      "Время работы сервера (в секундах)",
 	})
 
 	// Использование оперативной памяти (в байтах)
-	memoryUsageBytes = promauto.NewGauge(prometheus.GaugeOpts{
-		Namespace: "pdf_service",
+	r.memoryUsageBytes = f.NewGauge(prometheus.GaugeOpts{
+		Namespace: Namespace,
 		Name:      "memory_usage_bytes",
 		Help:      "Использование оперативной памяти (в байтах)",
 	})
 
 	// Средняя загрузка ЦПУ за последнюю минуту
-	cpuLoadAverage = promauto.NewGauge(prometheus.GaugeOpts{
-		Namespace: "pdf_service",
+	r.cpuLoadAverage = f.NewGauge(prometheus.GaugeOpts{
+		Namespace: Namespace,
 		Name:      "cpu_load_average",
 		Help:      "Средняя загрузка ЦПУ за последнюю минуту",
 	})
 
 	// Количество попыток повторной обработки при возникновении ошибок
-	retryAttempts = promauto.NewCounter(prometheus.CounterOpts{
-		Namespace: "pdf_service",
+	r.retryAttempts = f.NewCounter(prometheus.CounterOpts{
+		Namespace: Namespace,
 		Name:      "retry_attempts",
 		Help:      "Количество попыток повторной обработки при возникновении ошибок",
 	})
 
 	// Счётчик статусов операций (NEW, PROGRESS, DONE, ERROR)
-	operationStatusCounts = promauto.NewCounterVec(prometheus.CounterOpts{
-		Namespace: "pdf_service",
+	r.operationStatusCounts = f.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
 		Name:      "operation_status_counts",
 		Help:      "Счётчик статусов операций (NEW, PROGRESS, DONE, ERROR)",
 	}, []string{"status"})
 
-	// Количество успешных обращений к Memcached
-	cacheHits = promauto.NewCounter(prometheus.CounterOpts{
-		Namespace: "pdf_service",
+	// Количество найденных ключей при чтении из Memcached (get, get_multi по ключам, чтение в cas)
+	r.cacheHits = f.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
 		Name:      "cache_hits",
-		Help:      "Количество успешных обращений к Memcached",
-	})
+		Help:      "Количество найденных ключей при чтении из Memcached",
+	}, []string{"operation"})
 
-	// Количество пропущенных записей в Memcached.
-	cacheMisses = promauto.NewCounter(prometheus.CounterOpts{
-		Namespace: "pdf_service",
+	// Количество ненайденных ключей при чтении из Memcached
+	r.cacheMisses = f.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
 		Name:      "cache_misses",
-		Help:      "Количество пропущенных записей в Memcached",
+		Help:      "Количество ненайденных ключей при чтении из Memcached",
+	}, []string{"operation"})
+
+	// Количество ошибок Memcached (недоступен сервер, таймаут), не считая промахов
+	r.cacheErrors = f.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "cache_errors",
+		Help:      "Количество ошибок Memcached, не считая промахов",
+	}, []string{"operation"})
+
+	// Количество обращений к Memcached по операции и исходу (ok, miss, not_stored, conflict, error)
+	r.cacheRequestsTotal = f.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "cache_requests_total",
+		Help:      "Количество обращений к Memcached по операции и исходу",
+	}, []string{"operation", "result"})
+
+	// Время выполнения операции с Memcached (в секундах)
+	r.cacheOperationSeconds = f.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: Namespace,
+		Name:      "cache_operation_duration_seconds",
+		Help:      "Время выполнения операции с Memcached (в секундах)",
+		Buckets:   opts.DurationBuckets,
+	}, []string{"operation"})
+
+	// Кэш работает в памяти процесса, потому что Memcached недоступен (1) или через Memcached (0)
+	r.cacheDegraded = f.NewGauge(prometheus.GaugeOpts{
+		Namespace: Namespace,
+		Name:      "cache_degraded",
+		Help:      "1, если кэш работает в памяти процесса из-за недоступности Memcached, иначе 0",
 	})
 
 	// Количество превышений лимита запросов
-	rateLimitExceededCount = promauto.NewCounter(prometheus.CounterOpts{
-		Namespace: "pdf_service",
+	r.rateLimitExceededCount = f.NewCounter(prometheus.CounterOpts{
+		Namespace: Namespace,
 		Name:      "rate_limit_exceeded_count",
 		Help:      "Количество превышений лимита запросов",
 	})
 
 	// Количество запросов от каждого IP-адреса
-	requestCountByIP = promauto.NewCounterVec(prometheus.CounterOpts{
-		Namespace: "pdf_service",
+	r.requestCountByIP = f.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
 		Name:      "request_count_by_ip",
 		Help:      "Количество запросов от каждого IP-адреса",
 	}, []string{"ip"})
 
+	// Количество HTTP-запросов по маршруту, методу и коду ответа
+	r.httpRequestsTotal = f.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "http_requests_total",
+		Help:      "Количество HTTP-запросов по маршруту, методу и коду ответа",
+	}, []string{"route", "method", "code"})
+
+	// Время обработки HTTP-запроса (в секундах)
+	r.httpRequestDurationSeconds = f.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: Namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "Время обработки HTTP-запроса (в секундах)",
+		Buckets:   opts.DurationBuckets,
+	}, []string{"route", "method", "code"})
+
+	// Размер тела HTTP-ответа (в байтах)
+	r.httpResponseSizeBytes = f.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: Namespace,
+		Name:      "http_response_size_bytes",
+		Help:      "Размер тела HTTP-ответа (в байтах)",
+		Buckets:   opts.SizeBuckets,
+	}, []string{"route", "method", "code"})
+
 	// Количество успешных извлечений данных из PDF-файлов
-	dataExtractionSuccessCount = promauto.NewCounter(prometheus.CounterOpts{
-		Namespace: "pdf_service",
+	r.dataExtractionSuccessCount = f.NewCounter(prometheus.CounterOpts{
+		Namespace: Namespace,
 		Name:      "data_extraction_success_count",
 		Help:      "Количество успешных извлечений данных из PDF-файлов",
 	})
 
 	// Количество ошибок при извлечении данных
-	dataExtractionErrorCount = promauto.NewCounter(prometheus.CounterOpts{
-		Namespace: "pdf_service",
+	r.dataExtractionErrorCount = f.NewCounter(prometheus.CounterOpts{
+		Namespace: Namespace,
 		Name:      "data_extraction_error_count",
 		Help:      "Количество ошибок при извлечении данных",
 	})
 
 	// Время извлечения данных из одного PDF-файла (в секундах)
-	dataExtractionTimeSeconds = promauto.NewSummary(prometheus.SummaryOpts{
-		Namespace: "pdf_service",
+	r.dataExtractionTimeSeconds = f.NewHistogram(prometheus.HistogramOpts{
+		Namespace: Namespace,
 		Name:      "data_extraction_time_seconds",
 		Help:      "Время извлечения данных из одного PDF-файла (в секундах)",
+		Buckets:   opts.DurationBuckets,
 	})
 
 	// Количество успешных сравнений "до/после"
-	comparisonSuccessCount = promauto.NewCounter(prometheus.CounterOpts{
-		Namespace: "pdf_service",
+	r.comparisonSuccessCount = f.NewCounter(prometheus.CounterOpts{
+		Namespace: Namespace,
 		Name:      "comparison_success_count",
 		Help:      "Количество успешных сравнений до/после",
 	})
 
 	// Количество ошибок при сравнении "до/после"
-	comparisonErrorCount = promauto.NewCounter(prometheus.CounterOpts{
-		Namespace: "pdf_service",
+	r.comparisonErrorCount = f.NewCounter(prometheus.CounterOpts{
+		Namespace: Namespace,
 		Name:      "comparison_error_count",
 		Help:      "Количество ошибок при сравнении до/после",
 	})
 
 	// Время выполнения сравнения "до/после" (в секундах)
-	comparisonTimeSeconds = promauto.NewSummary(prometheus.SummaryOpts{
-		Namespace: "pdf_service",
+	r.comparisonTimeSeconds = f.NewHistogram(prometheus.HistogramOpts{
+		Namespace: Namespace,
 		Name:      "comparison_time_seconds",
 		Help:      "Время выполнения сравнения до/после (в секундах)",
+		Buckets:   opts.DurationBuckets,
 	})
 
 	// Количество успешных экспортов отчётов
-	exportSuccessCount = promauto.NewCounter(prometheus.CounterOpts{
-		Namespace: "pdf_service",
+	r.exportSuccessCount = f.NewCounter(prometheus.CounterOpts{
+		Namespace: Namespace,
 		Name:      "export_success_count",
 		Help:      "Количество успешных экспортов отчётов",
 	})
 
 	// Количество ошибок при экспорте отчётов
-	exportErrorCount = promauto.NewCounter(prometheus.CounterOpts{
-		Namespace: "pdf_service",
+	r.exportErrorCount = f.NewCounter(prometheus.CounterOpts{
+		Namespace: Namespace,
 		Name:      "export_error_count",
 		Help:      "Количество ошибок при экспорте отчётов",
 	})
 
 	// Время выполнения экспорта отчётов (в секундах)
-	exportTimeSeconds = promauto.NewSummary(prometheus.SummaryOpts{
-		Namespace: "pdf_service",
+	r.exportTimeSeconds = f.NewHistogram(prometheus.HistogramOpts{
+		Namespace: Namespace,
 		Name:      "export_time_seconds",
 		Help:      "Время выполнения экспорта отчётов (в секундах)",
+		Buckets:   opts.DurationBuckets,
 	})
-)
+
+	return r, nil
+}
+
+// validateBuckets проверяет, что границы строго возрастают: иначе prometheus паникует
+func validateBuckets(buckets []float64) error {
+	if !slices.IsSorted(buckets) || len(slices.Compact(slices.Clone(buckets))) != len(buckets) {
+		return fmt.Errorf("must be strictly increasing, got %v", buckets)
+	}
+	return nil
+}
+
+// Gatherer возвращает реестр для чтения собранных значений
+func (r *Registry) Gatherer() prometheus.Gatherer {
+	return r.registry
+}
+
+// Handler отдаёт метрики реестра в формате Prometheus
+func (r *Registry) Handler() http.Handler {
+	return promhttp.HandlerFor(r.registry, promhttp.HandlerOpts{Registry: r.registry})
+}
 
 // UpdateFileProcessingTime обновляет время обработки файла
-func UpdateFileProcessingTime(result string, duration float64) {
-	fileProcessingTimeSeconds.WithLabelValues(result).Observe(duration)
+func (r *Registry) UpdateFileProcessingTime(result string, duration float64) {
+	r.fileProcessingTimeSeconds.WithLabelValues(result).Observe(duration)
 }
 
 // UpdateFileSize обновляет размер загруженного файла
-func UpdateFileSize(size float64) {
-	fileSizeBytes.Observe(size)
+func (r *Registry) UpdateFileSize(size float64) {
+	r.fileSizeBytes.Observe(size)
 }
 
 // UpdateFileUploadSuccess увеличивает счётчик успешных загрузок файлов
-func UpdateFileUploadSuccess() {
-	fileUploadSuccessCount.Inc()
+func (r *Registry) UpdateFileUploadSuccess() {
+	r.fileUploadSuccessCount.Inc()
 }
 
 // UpdateFileUploadError увеличивает счётчик ошибок при загрузке файлов
-func UpdateFileUploadError() {
-	fileUploadErrorCount.Inc()
+func (r *Registry) UpdateFileUploadError() {
+	r.fileUploadErrorCount.Inc()
 }
 
 // UpdateCurrentFilesInProgress обновляет текущее количество файлов в процессе обработки
-func UpdateCurrentFilesInProgress(count float64) {
-	currentFilesInProgress.Set(count)
+func (r *Registry) UpdateCurrentFilesInProgress(count float64) {
+	r.currentFilesInProgress.Set(count)
 }
 
 // UpdateOperationsPerSecond увеличивает счётчик операций в секунду
-func UpdateOperationsPerSecond() {
-	operationsPerSecond.Inc()
+func (r *Registry) UpdateOperationsPerSecond() {
+	r.operationsPerSecond.Inc()
 }
 
 // UpdateQueueLength обновляет длину очереди задач
-func UpdateQueueLength(length float64) {
-	queueLength.Set(length)
+func (r *Registry) UpdateQueueLength(length float64) {
+	r.queueLength.Set(length)
 }
 
 // UpdateWorkerQueueDelay обновляет задержку между постановкой задачи в очередь и началом её обработки
-func UpdateWorkerQueueDelay(delay float64) {
-	workerQueueDelaySeconds.Observe(delay)
+func (r *Registry) UpdateWorkerQueueDelay(delay float64) {
+	r.workerQueueDelaySeconds.Observe(delay)
 }
 
 // UpdateServerUptime обновляет время работы сервера
-func UpdateServerUptime() {
-	go func() {
-		startTime := time.Now()
-		ticker := time.NewTicker(time.Minute)
-		defer ticker.Stop()
-
-		for range ticker.C {
-			uptime := time.Since(startTime).Seconds()
-			serverUptimeSeconds.Set(uptime)
-		}
-	}()
+func (r *Registry) UpdateServerUptime(seconds float64) {
+	r.serverUptimeSeconds.Set(seconds)
 }
 
 // UpdateMemoryUsage обновляет использование оперативной памяти
-func UpdateMemoryUsage(bytes float64) {
-	memoryUsageBytes.Set(bytes)
+func (r *Registry) UpdateMemoryUsage(bytes float64) {
+	r.memoryUsageBytes.Set(bytes)
 }
 
 // UpdateCPULoadAverage обновляет среднюю загрузку ЦПУ
-func UpdateCPULoadAverage(load float64) {
-	cpuLoadAverage.Set(load)
+func (r *Registry) UpdateCPULoadAverage(load float64) {
+	r.cpuLoadAverage.Set(load)
 }
 
 // UpdateRetryAttempts увеличивает счётчик попыток повторной обработки
-func UpdateRetryAttempts() {
-	retryAttempts.Inc()
+func (r *Registry) UpdateRetryAttempts() {
+	r.retryAttempts.Inc()
 }
 
 // UpdateOperationStatus увеличивает счётчик статусов операций
-func UpdateOperationStatus(status string) {
-	operationStatusCounts.WithLabelValues(status).Inc()
+func (r *Registry) UpdateOperationStatus(status string) {
+	r.operationStatusCounts.WithLabelValues(status).Inc()
+}
+
+// UpdateCacheHits увеличивает счётчик найденных при чтении ключей
+func (r *Registry) UpdateCacheHits(operation string) {
+	r.cacheHits.WithLabelValues(operation).Inc()
+}
+
+// UpdateCacheMisses увеличивает счётчик ненайденных при чтении ключей
+func (r *Registry) UpdateCacheMisses(operation string) {
+	r.cacheMisses.WithLabelValues(operation).Inc()
+}
+
+// UpdateCacheErrors увеличивает счётчик ошибок Memcached
+func (r *Registry) UpdateCacheErrors(operation string) {
+	r.cacheErrors.WithLabelValues(operation).Inc()
 }
 
-// UpdateCacheHits увеличивает счётчик успешных обращений к Memcached
-func UpdateCacheHits() {
-	cacheHits.Inc()
+// UpdateCacheRequest учитывает обращение к Memcached с его исходом
+func (r *Registry) UpdateCacheRequest(operation, result string) {
+	r.cacheRequestsTotal.WithLabelValues(operation, result).Inc()
 }
 
-// UpdateCacheMisses увеличивает счётчик пропущенных записей в Memcached
-func UpdateCacheMisses() {
-	cacheMisses.Inc()
+// UpdateCacheOperationTime обновляет время выполнения операции с Memcached
+func (r *Registry) UpdateCacheOperationTime(operation string, duration float64) {
+	r.cacheOperationSeconds.WithLabelValues(operation).Observe(duration)
+}
+
+// UpdateCacheDegraded отмечает, работает ли кэш в памяти процесса вместо Memcached
+func (r *Registry) UpdateCacheDegraded(degraded bool) {
+	if degraded {
+		r.cacheDegraded.Set(1)
+		return
+	}
+	r.cacheDegraded.Set(0)
 }
 
 // UpdateRateLimitExceeded увеличивает счётчик превышений лимита запросов
-func UpdateRateLimitExceeded() {
-	rateLimitExceededCount.Inc()
+func (r *Registry) UpdateRateLimitExceeded() {
+	r.rateLimitExceededCount.Inc()
 }
 
 // UpdateRequestCountByIP увеличивает счётчик запросов от каждого IP-адреса
-func UpdateRequestCountByIP(ip string) {
-	requestCountByIP.WithLabelValues(ip).Inc()
+func (r *Registry) UpdateRequestCountByIP(ip string) {
+	r.requestCountByIP.WithLabelValues(ip).Inc()
+}
+
+// RemoveRequestCountByIP удаляет серию IP-адреса, вытесненного из учитываемых по отдельности
+func (r *Registry) RemoveRequestCountByIP(ip string) {
+	r.requestCountByIP.DeleteLabelValues(ip)
+}
+
+// UpdateHTTPRequest учитывает обработанный HTTP-запрос: количество, длительность и размер ответа
+func (r *Registry) UpdateHTTPRequest(route, method, code string, duration, size float64) {
+	r.httpRequestsTotal.WithLabelValues(route, method, code).Inc()
+	r.httpRequestDurationSeconds.WithLabelValues(route, method, code).Observe(duration)
+	r.httpResponseSizeBytes.WithLabelValues(route, method, code).Observe(size)
 }
 
 // UpdateDataExtractionSuccess увеличивает счётчик успешных извлечений данных из PDF-файлов
-func UpdateDataExtractionSuccess() {
-	dataExtractionSuccessCount.Inc()
+func (r *Registry) UpdateDataExtractionSuccess() {
+	r.dataExtractionSuccessCount.Inc()
 }
 
 // UpdateDataExtractionError увеличивает счётчик ошибок при извлечении данных
-func UpdateDataExtractionError() {
-	dataExtractionErrorCount.Inc()
+func (r *Registry) UpdateDataExtractionError() {
+	r.dataExtractionErrorCount.Inc()
 }
 
 // UpdateDataExtractionTime обновляет время извлечения данных из одного PDF-файла
-func UpdateDataExtractionTime(duration float64) {
-	dataExtractionTimeSeconds.Observe(duration)
+func (r *Registry) UpdateDataExtractionTime(duration float64) {
+	r.dataExtractionTimeSeconds.Observe(duration)
 }
 
 // UpdateComparisonSuccess увеличивает счётчик успешных сравнений "до/после"
-func UpdateComparisonSuccess() {
-	comparisonSuccessCount.Inc()
+func (r *Registry) UpdateComparisonSuccess() {
+	r.comparisonSuccessCount.Inc()
 }
 
 // UpdateComparisonError увеличивает счётчик ошибок при сравнении "до/после"
-func UpdateComparisonError() {
-	comparisonErrorCount.Inc()
+func (r *Registry) UpdateComparisonError() {
+	r.comparisonErrorCount.Inc()
 }
 
 // UpdateComparisonTime обновляет время выполнения сравнения "до/после"
-func UpdateComparisonTime(duration float64) {
-	comparisonTimeSeconds.Observe(duration)
+func (r *Registry) UpdateComparisonTime(duration float64) {
+	r.comparisonTimeSeconds.Observe(duration)
 }
 
 // UpdateExportSuccess увеличивает счётчик успешных экспортов отчётов
-func UpdateExportSuccess() {
-	exportSuccessCount.Inc()
+func (r *Registry) UpdateExportSuccess() {
+	r.exportSuccessCount.Inc()
 }
 
 // UpdateExportError увеличивает счётчик ошибок при экспорте отчётов
-func UpdateExportError() {
-	exportErrorCount.Inc()
+func (r *Registry) UpdateExportError() {
+	r.exportErrorCount.Inc()
 }
 
 // UpdateExportTime обновляет время выполнения экспорта отчётов
-func UpdateExportTime(duration float64) {
-	exportTimeSeconds.Observe(duration)
-}
-
-func InitMetrics() {
-	http.Handle("/metrics", promhttp.Handler())
+func (r *Registry) UpdateExportTime(duration float64) {
+	r.exportTimeSeconds.Observe(duration)
 }
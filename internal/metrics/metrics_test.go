@@ -0,0 +1,133 @@
+package metrics
+
+import (
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	dto "github.com/prometheus/client_model/go"
+)
+
+func TestRegistryNamespaceAndBuckets(t *testing.T) {
+	durations := []float64{0.1, 1, 10}
+	sizes := []float64{100, 1000}
+	r, err := NewRegistry(Options{DurationBuckets: durations, SizeBuckets: sizes})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r.UpdateFileProcessingTime("done", 0.5)
+	r.UpdateFileSize(500)
+	r.UpdateFileUploadSuccess()
+	r.UpdateFileUploadError()
+	r.UpdateCurrentFilesInProgress(1)
+	r.UpdateOperationsPerSecond()
+	r.UpdateQueueLength(2)
+	r.UpdateWorkerQueueDelay(0.2)
+	r.UpdateServerUptime(3)
+	r.UpdateMemoryUsage(4096)
+	r.UpdateCPULoadAverage(0.5)
+	r.UpdateRetryAttempts()
+	r.UpdateOperationStatus("DONE")
+	r.UpdateCacheHits("get")
+	r.UpdateCacheMisses("get")
+	r.UpdateCacheErrors("get")
+	r.UpdateCacheRequest("get", "ok")
+	r.UpdateCacheOperationTime("get", 0.01)
+	r.UpdateCacheDegraded(true)
+	r.UpdateRateLimitExceeded()
+	r.UpdateRequestCountByIP("10.0.0.1")
+	r.UpdateHTTPRequest("/status", "GET", "200", 0.05, 10)
+	r.UpdateDataExtractionSuccess()
+	r.UpdateDataExtractionError()
+	r.UpdateDataExtractionTime(1)
+	r.UpdateComparisonSuccess()
+	r.UpdateComparisonError()
+	r.UpdateComparisonTime(1)
+	r.UpdateExportSuccess()
+	r.UpdateExportError()
+	r.UpdateExportTime(1)
+
+	families, err := r.Gatherer().Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	own := 0
+	for _, family := range families {
+		name := family.GetName()
+		// Метрики среды выполнения Go и процесса приходят из стандартных коллекторов без пространства имён
+		if strings.HasPrefix(name, "go_") || strings.HasPrefix(name, "process_") {
+			continue
+		}
+		if !strings.HasPrefix(name, Namespace+"_") {
+			t.Errorf("metric %s is outside the %s namespace", name, Namespace)
+			continue
+		}
+		own++
+		if family.GetType() != dto.MetricType_HISTOGRAM {
+			continue
+		}
+		want := durations
+		if strings.HasSuffix(name, "_bytes") {
+			want = sizes
+		}
+		for _, m := range family.GetMetric() {
+			if got := upperBounds(m.GetHistogram()); !slices.Equal(got, want) {
+				t.Errorf("%s buckets = %v, want %v", name, got, want)
+			}
+		}
+	}
+
+	// Каждое поле Registry, кроме самого prometheus.Registry, — одна метрика
+	if fields := reflect.TypeOf(Registry{}).NumField() - 1; own != fields {
+		t.Errorf("gathered %d %s metrics, want %d", own, Namespace, fields)
+	}
+}
+
+func TestValidateBuckets(t *testing.T) {
+	tests := []struct {
+		buckets []float64
+		wantErr bool
+	}{
+		{buckets: []float64{0.1, 1, 10}},
+		{buckets: []float64{1}},
+		{buckets: []float64{1, 0.1, 10}, wantErr: true},
+		{buckets: []float64{0.1, 1, 1, 10}, wantErr: true},
+		{buckets: []float64{10, 1}, wantErr: true},
+	}
+	for _, tt := range tests {
+		if err := validateBuckets(tt.buckets); (err != nil) != tt.wantErr {
+			t.Errorf("validateBuckets(%v) = %v, wantErr %v", tt.buckets, err, tt.wantErr)
+		}
+	}
+
+	if _, err := NewRegistry(Options{DurationBuckets: []float64{5, 1}}); err == nil {
+		t.Error("NewRegistry accepted unsorted duration buckets")
+	}
+	if _, err := NewRegistry(Options{SizeBuckets: []float64{1024, 1024}}); err == nil {
+		t.Error("NewRegistry accepted duplicate size buckets")
+	}
+}
+
+func upperBounds(h *dto.Histogram) []float64 {
+	bounds := make([]float64, 0, len(h.GetBucket()))
+	for _, b := range h.GetBucket() {
+		bounds = append(bounds, b.GetUpperBound())
+	}
+	return bounds
+}
+
+// useRegistry подменяет реестр по умолчанию на время теста. Внешним пакетам то же
+// даёт metricstest.UseRegistry: импортировать его отсюда нельзя из-за цикла.
+func useRegistry(t *testing.T) *Registry {
+	t.Helper()
+	registry, err := NewRegistry(Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	prev := Default()
+	SetDefault(registry)
+	t.Cleanup(func() { SetDefault(prev) })
+	return registry
+}
@@ -0,0 +1,46 @@
+// Package metricstest содержит помощники тестов, проверяющих метрики
+package metricstest
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/Caritas-Team/reviewer/internal/metrics"
+)
+
+// UseRegistry подменяет реестр метрик по умолчанию на новый до конца теста
+func UseRegistry(t testing.TB) *metrics.Registry {
+	t.Helper()
+	registry, err := metrics.NewRegistry(metrics.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	prev := metrics.Default()
+	metrics.SetDefault(registry)
+	t.Cleanup(func() { metrics.SetDefault(prev) })
+	return registry
+}
+
+// Counters возвращает значения счётчика name (без пространства имён) по сериям
+// "метка=значение,..."; метки идут в алфавитном порядке
+func Counters(t testing.TB, registry *metrics.Registry, name string) map[string]float64 {
+	t.Helper()
+	families, err := registry.Gatherer().Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := make(map[string]float64)
+	for _, family := range families {
+		if family.GetName() != metrics.Namespace+"_"+name {
+			continue
+		}
+		for _, m := range family.GetMetric() {
+			labels := make([]string, 0, len(m.GetLabel()))
+			for _, l := range m.GetLabel() {
+				labels = append(labels, l.GetName()+"="+l.GetValue())
+			}
+			out[strings.Join(labels, ",")] = m.GetCounter().GetValue()
+		}
+	}
+	return out
+}
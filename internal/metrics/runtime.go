@@ -0,0 +1,85 @@
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultCollectInterval — период замеров, если collect_interval не задан
+	DefaultCollectInterval = 15 * time.Second
+
+	loadavgPath = "/proc/loadavg"
+)
+
+// RuntimeCollector периодически обновляет использование памяти, загрузку ЦПУ и аптайм
+type RuntimeCollector struct {
+	interval    time.Duration
+	started     time.Time
+	loadavgPath string
+}
+
+// NewRuntimeCollector создаёт сборщик; аптайм отсчитывается от момента создания
+func NewRuntimeCollector(interval time.Duration) *RuntimeCollector {
+	if interval <= 0 {
+		interval = DefaultCollectInterval
+	}
+	return &RuntimeCollector{
+		interval:    interval,
+		started:     time.Now(),
+		loadavgPath: loadavgPath,
+	}
+}
+
+// Run делает замер сразу и затем раз в интервал; возвращается после отмены ctx
+func (c *RuntimeCollector) Run(ctx context.Context) {
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+
+	// Без /proc (не Linux) загрузку ЦПУ не обновляем, но и не пишем ошибку на каждом замере
+	loadavg := true
+	for {
+		c.collectMemory()
+		UpdateServerUptime(time.Since(c.started).Seconds())
+		if loadavg {
+			load, err := readLoadAverage(c.loadavgPath)
+			if err != nil {
+				slog.Warn("cpu load average unavailable", "err", err)
+				loadavg = false
+			} else {
+				UpdateCPULoadAverage(load)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (c *RuntimeCollector) collectMemory() {
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+	UpdateMemoryUsage(float64(m.Sys))
+}
+
+// readLoadAverage возвращает среднюю загрузку за минуту — первое поле /proc/loadavg
+func readLoadAverage(path string) (float64, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	fields := strings.Fields(string(data))
+	if len(fields) == 0 {
+		return 0, fmt.Errorf("unexpected %s format", path)
+	}
+	return strconv.ParseFloat(fields[0], 64)
+}
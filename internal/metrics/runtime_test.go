@@ -0,0 +1,104 @@
+package metrics
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestReadLoadAverage(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    float64
+		wantErr bool
+	}{
+		{name: "linux", content: "0.42 0.30 0.25 1/123 4567\n", want: 0.42},
+		{name: "idle", content: "0.00 0.01 0.05 1/90 12\n", want: 0},
+		{name: "empty", content: "", wantErr: true},
+		{name: "garbage", content: "load high\n", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := writeLoadavg(t, tt.content)
+			got, err := readLoadAverage(path)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("load = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := readLoadAverage(filepath.Join(t.TempDir(), "missing")); err == nil {
+		t.Error("missing file: no error")
+	}
+}
+
+func TestRuntimeCollectorRunStops(t *testing.T) {
+	registry := useRegistry(t)
+
+	goroutines := runtime.NumGoroutine()
+
+	c := NewRuntimeCollector(time.Millisecond)
+	c.loadavgPath = writeLoadavg(t, "1.50 1.00 0.50 2/200 999\n")
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		c.Run(ctx)
+	}()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for gauge(t, registry, "cpu_load_average") != 1.5 {
+		if time.Now().After(deadline) {
+			t.Fatal("cpu_load_average was not collected from the fixture")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if gauge(t, registry, "memory_usage_bytes") <= 0 {
+		t.Error("memory_usage_bytes was not collected")
+	}
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run did not return after ctx cancellation")
+	}
+	deadline = time.Now().Add(5 * time.Second)
+	for runtime.NumGoroutine() > goroutines {
+		if time.Now().After(deadline) {
+			t.Fatalf("%d goroutines after Run returned, want at most %d", runtime.NumGoroutine(), goroutines)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func writeLoadavg(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "loadavg")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// gauge возвращает значение метрики без меток
+func gauge(t *testing.T, r *Registry, name string) float64 {
+	t.Helper()
+	families, err := r.Gatherer().Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, family := range families {
+		if family.GetName() == Namespace+"_"+name && len(family.GetMetric()) == 1 {
+			return family.GetMetric()[0].GetGauge().GetValue()
+		}
+	}
+	return 0
+}
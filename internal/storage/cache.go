@@ -0,0 +1,53 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/bradfitz/gomemcache/memcache"
+)
+
+// keyPrefix — префикс ключей операций внутри пространства имён кэша
+const keyPrefix = "operation:"
+
+// cacheClient — часть memecached.Cache, нужная хранилищу
+type cacheClient interface {
+	Get(ctx context.Context, key string) ([]byte, error)
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+}
+
+// CacheStore хранит операции в кэше в виде JSON: в memcached или, если он выключен, в памяти процесса
+type CacheStore struct {
+	cache cacheClient
+	ttl   time.Duration
+}
+
+func NewCacheStore(cache cacheClient, ttl time.Duration) *CacheStore {
+	return &CacheStore{cache: cache, ttl: ttl}
+}
+
+func (s *CacheStore) Save(ctx context.Context, op Operation) error {
+	data, err := json.Marshal(op)
+	if err != nil {
+		return fmt.Errorf("encode operation: %w", err)
+	}
+	return s.cache.Set(ctx, keyPrefix+op.ID, data, s.ttl)
+}
+
+func (s *CacheStore) Get(ctx context.Context, id string) (Operation, error) {
+	var op Operation
+	data, err := s.cache.Get(ctx, keyPrefix+id)
+	if errors.Is(err, memcache.ErrCacheMiss) {
+		return op, ErrNotFound
+	}
+	if err != nil {
+		return op, err
+	}
+	if err := json.Unmarshal(data, &op); err != nil {
+		return op, fmt.Errorf("decode operation: %w", err)
+	}
+	return op, nil
+}
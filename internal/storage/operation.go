@@ -0,0 +1,71 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/Caritas-Team/reviewer/internal/config"
+	"github.com/Caritas-Team/reviewer/internal/memecached"
+	"github.com/Caritas-Team/reviewer/internal/metrics"
+)
+
+// Status — статус обработки операции
+type Status string
+
+const (
+	StatusNew      Status = "NEW"
+	StatusProgress Status = "PROGRESS"
+	StatusDone     Status = "DONE"
+	StatusError    Status = "ERROR"
+)
+
+var ErrNotFound = errors.New("operation not found")
+
+// Operation — запись об операции обработки одного файла
+type Operation struct {
+	ID         string    `json:"id"`
+	Status     Status    `json:"status"`
+	Error      string    `json:"error,omitempty"`
+	FilePath   string    `json:"file_path"`
+	ResultPath string    `json:"result_path,omitempty"`
+	ReportPath string    `json:"report_path,omitempty"`
+	OwnerKey   string    `json:"owner_key"`
+	RequestID  string    `json:"request_id,omitempty"` // X-Request-UUID запроса, создавшего операцию
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// OperationStore — хранилище операций с ограниченным временем жизни записей
+type OperationStore interface {
+	// Save создаёт или перезаписывает операцию как есть; время проставляет SetStatus
+	Save(ctx context.Context, op Operation) error
+	// Get возвращает операцию или ErrNotFound
+	Get(ctx context.Context, id string) (Operation, error)
+}
+
+// NewOperationStore хранит операции в кэше; при memcached.enable: false кэш работает в памяти процесса
+func NewOperationStore(cfg config.Config, cache *memecached.Cache) OperationStore {
+	return NewCacheStore(cache, time.Duration(cfg.Memcached.DefaultTTL)*time.Second)
+}
+
+// SetStatus переводит операцию в новый статус, сохраняет её и учитывает переход в метриках.
+// Возвращённая операция совпадает с сохранённой, поэтому created_at переживает следующие переходы.
+func SetStatus(ctx context.Context, store OperationStore, op Operation, status Status, errMsg string) (Operation, error) {
+	op.Status = status
+	op.Error = errMsg
+	touch(&op, time.Now())
+	if err := store.Save(ctx, op); err != nil {
+		return op, err
+	}
+	metrics.UpdateOperationStatus(string(status))
+	return op, nil
+}
+
+// touch проставляет время создания и обновления перед сохранением
+func touch(op *Operation, now time.Time) {
+	if op.CreatedAt.IsZero() {
+		op.CreatedAt = now
+	}
+	op.UpdatedAt = now
+}
@@ -0,0 +1,45 @@
+package storage_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Caritas-Team/reviewer/internal/storage"
+	"github.com/Caritas-Team/reviewer/internal/storage/storagetest"
+)
+
+func TestSetStatusKeepsCreatedAt(t *testing.T) {
+	ctx := context.Background()
+	store := storagetest.NewMemoryStore(t)
+
+	op, err := storage.SetStatus(ctx, store, storage.Operation{ID: "op-1"}, storage.StatusNew, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if op.CreatedAt.IsZero() || !op.UpdatedAt.Equal(op.CreatedAt) {
+		t.Fatalf("new operation: created_at = %v, updated_at = %v", op.CreatedAt, op.UpdatedAt)
+	}
+	created := op.CreatedAt
+
+	time.Sleep(time.Millisecond)
+	for _, status := range []storage.Status{storage.StatusProgress, storage.StatusDone} {
+		if op, err = storage.SetStatus(ctx, store, op, status, ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	stored, err := store.Get(ctx, "op-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Status != op.Status || !stored.UpdatedAt.Equal(op.UpdatedAt) {
+		t.Errorf("stored = %+v, returned = %+v", stored, op)
+	}
+	if !stored.CreatedAt.Equal(created) {
+		t.Errorf("created_at = %v, want %v", stored.CreatedAt, created)
+	}
+	if !stored.UpdatedAt.After(created) {
+		t.Errorf("updated_at = %v, want after %v", stored.UpdatedAt, created)
+	}
+}
@@ -0,0 +1,23 @@
+// Package storagetest содержит помощники тестов, которым нужно хранилище операций
+package storagetest
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Caritas-Team/reviewer/internal/config"
+	"github.com/Caritas-Team/reviewer/internal/memecached"
+	"github.com/Caritas-Team/reviewer/internal/storage"
+)
+
+// NewMemoryStore возвращает хранилище операций поверх кэша в памяти процесса, как при memcached.enable: false
+func NewMemoryStore(t testing.TB) storage.OperationStore {
+	t.Helper()
+	cfg := config.Config{Memcached: config.Memcached{DefaultTTL: 60}}
+	cache, err := memecached.NewCache(context.Background(), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = cache.Close() })
+	return storage.NewOperationStore(cfg, cache)
+}
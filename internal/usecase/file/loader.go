@@ -1 +1,276 @@
 package file
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Caritas-Team/reviewer/internal/metrics"
+	"github.com/Caritas-Team/reviewer/internal/storage"
+	"github.com/ledongthuc/pdf"
+)
+
+var (
+	ErrNotPDF       = errors.New("file is not a PDF document")
+	ErrNoIndicators = errors.New("no diagnostic indicators found")
+)
+
+// DiagnosticReport — данные одного диагностического заключения
+type DiagnosticReport struct {
+	ChildID    string      `json:"child_id"`
+	TestDate   time.Time   `json:"test_date"`
+	Specialist string      `json:"specialist,omitempty"`
+	Indicators []Indicator `json:"indicators"`
+}
+
+// Indicator — строка таблицы показателей: название, балл и норма в исходной записи
+type Indicator struct {
+	Name  string  `json:"name"`
+	Score float64 `json:"score"`
+	Norm  string  `json:"norm,omitempty"`
+}
+
+// Page — текст страницы, разбитый на строки и ячейки
+type Page struct {
+	Rows [][]string
+}
+
+var (
+	childLabels      = []string{"ребёнок", "ребенок", "фио", "код ребёнка", "код ребенка", "id", "child"}
+	dateLabels       = []string{"дата", "дата обследования", "дата диагностики", "дата тестирования", "date", "test date"}
+	specialistLabels = []string{"специалист", "психолог", "логопед", "дефектолог", "specialist"}
+
+	dateLayouts = []string{"02.01.2006", "2.1.2006", "2006-01-02", "02/01/2006"}
+
+	numberRe = regexp.MustCompile(`^[-+]?\d+(?:[.,]\d+)?$`)
+	rangeRe  = regexp.MustCompile(`^([-+]?\d+(?:[.,]\d+)?)\s*[-–—]\s*([-+]?\d+(?:[.,]\d+)?)$`)
+	boundRe  = regexp.MustCompile(`^(>=|<=|≥|≤|>|<|от|до)\s*([-+]?\d+(?:[.,]\d+)?)$`)
+	spacesRe = regexp.MustCompile(`\s{2,}|\t`)
+)
+
+// ExtractReport — обработчик планировщика: извлекает данные из PDF и сохраняет их в JSON рядом с файлом
+func ExtractReport(ctx context.Context, op storage.Operation) (storage.Operation, error) {
+	report, err := LoadReport(ctx, op.FilePath)
+	if err != nil {
+		return op, err
+	}
+
+	path := strings.TrimSuffix(op.FilePath, filepath.Ext(op.FilePath)) + ".json"
+	if err := WriteReport(path, report); err != nil {
+		return op, err
+	}
+	op.ReportPath = path
+	op.ResultPath = op.FilePath
+	return op, nil
+}
+
+// LoadReport читает PDF-файл и разбирает его в DiagnosticReport
+func LoadReport(ctx context.Context, path string) (report DiagnosticReport, err error) {
+	start := time.Now()
+	defer func() {
+		metrics.UpdateDataExtractionTime(time.Since(start).Seconds())
+		if err != nil {
+			metrics.UpdateDataExtractionError()
+			return
+		}
+		metrics.UpdateDataExtractionSuccess()
+	}()
+
+	f, err := os.Open(filepath.Clean(path))
+	if err != nil {
+		return report, fmt.Errorf("open upload: %w", err)
+	}
+	defer func() { _ = f.Close() }()
+
+	info, err := f.Stat()
+	if err != nil {
+		return report, fmt.Errorf("stat upload: %w", err)
+	}
+
+	pages, err := ReadPages(ctx, f, info.Size())
+	if err != nil {
+		return report, err
+	}
+	return ParseReport(pages)
+}
+
+// ReadPages извлекает текст всех страниц PDF, сгруппированный в строки и ячейки
+func ReadPages(ctx context.Context, r io.ReaderAt, size int64) (pages []Page, err error) {
+	// Разборщик PDF сообщает о повреждённых файлах через panic
+	defer func() {
+		if p := recover(); p != nil {
+			pages = nil
+			err = fmt.Errorf("%w: %v", ErrNotPDF, p)
+		}
+	}()
+
+	reader, err := pdf.NewReader(r, size)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrNotPDF, err)
+	}
+
+	for i := 1; i <= reader.NumPage(); i++ {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		p := reader.Page(i)
+		if p.V.IsNull() {
+			continue
+		}
+		runs, err := pageRuns(ctx, p)
+		if err != nil {
+			return nil, err
+		}
+		pages = append(pages, Page{Rows: layoutRows(runs)})
+	}
+	return pages, nil
+}
+
+// ParseReport сопоставляет строки страниц с полями заключения и таблицей показателей
+func ParseReport(pages []Page) (DiagnosticReport, error) {
+	var report DiagnosticReport
+	for _, page := range pages {
+		for _, row := range page.Rows {
+			if label, value, ok := labelled(row); ok {
+				switch {
+				case report.ChildID == "" && matchLabel(label, childLabels):
+					report.ChildID = value
+					continue
+				case report.TestDate.IsZero() && slices.Contains(dateLabels, label):
+					if date, ok := parseDate(value); ok {
+						report.TestDate = date
+						continue
+					}
+				case report.Specialist == "" && matchLabel(label, specialistLabels):
+					report.Specialist = value
+					continue
+				}
+			}
+			if indicator, ok := parseIndicator(row); ok {
+				report.Indicators = append(report.Indicators, indicator)
+			}
+		}
+	}
+	if len(report.Indicators) == 0 {
+		return report, ErrNoIndicators
+	}
+	return report, nil
+}
+
+// labelled разбирает строку вида "Метка: значение", в том числе когда значение в соседней ячейке
+func labelled(row []string) (label, value string, ok bool) {
+	joined := strings.Join(row, " ")
+	label, value, ok = strings.Cut(joined, ":")
+	if !ok {
+		return "", "", false
+	}
+	label = strings.ToLower(strings.TrimSpace(label))
+	value = strings.TrimSpace(value)
+	return label, value, value != ""
+}
+
+// matchLabel допускает уточнения после метки: "ФИО ребёнка", "Специалист (логопед)"
+func matchLabel(label string, candidates []string) bool {
+	for _, c := range candidates {
+		if label == c || strings.HasPrefix(label, c+" ") {
+			return true
+		}
+	}
+	return false
+}
+
+func parseDate(value string) (time.Time, bool) {
+	fields := strings.Fields(value)
+	if len(fields) == 0 {
+		return time.Time{}, false
+	}
+	for _, layout := range dateLayouts {
+		if t, err := time.Parse(layout, fields[0]); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// parseIndicator принимает строки "название | балл [| норма]"
+func parseIndicator(row []string) (Indicator, bool) {
+	if len(row) < 2 || numberRe.MatchString(row[0]) {
+		return Indicator{}, false
+	}
+	score, ok := parseNumber(row[1])
+	if !ok {
+		return Indicator{}, false
+	}
+	indicator := Indicator{Name: row[0], Score: score}
+	if len(row) > 2 {
+		indicator.Norm = strings.Join(row[2:], " ")
+	}
+	return indicator, true
+}
+
+func parseNumber(s string) (float64, bool) {
+	s = strings.TrimSpace(s)
+	if !numberRe.MatchString(s) {
+		return 0, false
+	}
+	v, err := strconv.ParseFloat(strings.Replace(s, ",", ".", 1), 64)
+	return v, err == nil
+}
+
+// NormRange разбирает норму вида "10-15", "≥ 12" или "до 5"; отсутствующая граница равна ±Inf
+func (i Indicator) NormRange() (lo, hi float64, ok bool) {
+	norm := strings.TrimSpace(i.Norm)
+	if m := rangeRe.FindStringSubmatch(norm); m != nil {
+		lo, _ = parseNumber(m[1])
+		hi, _ = parseNumber(m[2])
+		return lo, hi, true
+	}
+	if m := boundRe.FindStringSubmatch(norm); m != nil {
+		v, _ := parseNumber(m[2])
+		switch m[1] {
+		case ">=", "≥", ">", "от":
+			return v, math.Inf(1), true
+		default:
+			return math.Inf(-1), v, true
+		}
+	}
+	if v, ok := parseNumber(norm); ok {
+		return v, v, true
+	}
+	return 0, 0, false
+}
+
+// WriteReport сохраняет извлечённые данные в JSON
+func WriteReport(path string, report DiagnosticReport) error {
+	data, err := json.Marshal(report)
+	if err != nil {
+		return fmt.Errorf("encode report: %w", err)
+	}
+	if err := os.WriteFile(filepath.Clean(path), data, 0o600); err != nil {
+		return fmt.Errorf("write report: %w", err)
+	}
+	return nil
+}
+
+// ReadReport читает данные, сохранённые WriteReport
+func ReadReport(path string) (DiagnosticReport, error) {
+	var report DiagnosticReport
+	data, err := os.ReadFile(filepath.Clean(path))
+	if err != nil {
+		return report, fmt.Errorf("read report: %w", err)
+	}
+	if err := json.Unmarshal(data, &report); err != nil {
+		return report, fmt.Errorf("decode report: %w", err)
+	}
+	return report, nil
+}
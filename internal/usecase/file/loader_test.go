@@ -0,0 +1,165 @@
+package file
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// Файлы testdata собираются testdata/gen.go
+var identityRows = [][]string{
+	{"Заключение по результатам диагностики"},
+	{"Ребёнок: ID-0042"},
+	{"Дата обследования: 10.01.2025"},
+	{"Специалист: Иванова А. А."},
+	{"Показатель", "Балл", "Норма"},
+	{"Внимание", "12", "10-15"},
+	{"Память", "8,5", "≥ 9"},
+	{"Речь", "14"},
+}
+
+func TestReadPages(t *testing.T) {
+	tests := []struct {
+		file    string
+		want    [][]string
+		wantErr error
+	}{
+		{file: "identity-h.pdf", want: identityRows},
+		// /W с диапазонами до 2^31 и за пределами int32 не должен расходовать память и время
+		{file: "huge-widths.pdf", want: identityRows},
+		// Тысячи повторов "0 65535 w" читаются не дальше maxWidthEntries ширин
+		{file: "repeated-widths.pdf", want: identityRows},
+		{file: "split-labels.pdf", want: [][]string{
+			{"Child", "ID:", "C-17"},
+			{"Test", "date:", "2025-03-01"},
+			{"Specialist:", "J. Smith"},
+			{"Attention", "12", "10-15"},
+			{"Memory", "8.5", ">= 9"},
+		}},
+		{file: "truncated.pdf", wantErr: ErrNotPDF},
+		{file: "broken-xref.pdf", wantErr: ErrNotPDF},
+		{file: "not-pdf.pdf", wantErr: ErrNotPDF},
+	}
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			pages, err := readTestdata(t, tt.file)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("err = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(pages) != 1 {
+				t.Fatalf("got %d pages, want 1", len(pages))
+			}
+			if !reflect.DeepEqual(pages[0].Rows, tt.want) {
+				t.Errorf("rows = %q\nwant %q", pages[0].Rows, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadPagesCanceled(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("testdata", "repeated-widths.pdf"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := ReadPages(ctx, bytes.NewReader(data), int64(len(data))); !errors.Is(err, context.Canceled) {
+		t.Fatalf("err = %v, want %v", err, context.Canceled)
+	}
+}
+
+func TestParseReport(t *testing.T) {
+	tests := []struct {
+		name    string
+		rows    [][]string
+		want    DiagnosticReport
+		wantErr error
+	}{
+		{
+			name: "identity-h",
+			rows: identityRows,
+			want: DiagnosticReport{
+				ChildID:    "ID-0042",
+				TestDate:   time.Date(2025, 1, 10, 0, 0, 0, 0, time.UTC),
+				Specialist: "Иванова А. А.",
+				Indicators: []Indicator{
+					{Name: "Внимание", Score: 12, Norm: "10-15"},
+					{Name: "Память", Score: 8.5, Norm: "≥ 9"},
+					{Name: "Речь", Score: 14},
+				},
+			},
+		},
+		{
+			name: "labels split across cells",
+			rows: [][]string{
+				{"Child", "ID:", "C-17"},
+				{"Test", "date:", "2025-03-01"},
+				{"Specialist:", "J. Smith"},
+				{"Attention", "12", "10-15"},
+			},
+			want: DiagnosticReport{
+				ChildID:    "C-17",
+				TestDate:   time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC),
+				Specialist: "J. Smith",
+				Indicators: []Indicator{{Name: "Attention", Score: 12, Norm: "10-15"}},
+			},
+		},
+		{
+			name: "qualified labels",
+			rows: [][]string{
+				{"ФИО ребёнка: Петров П."},
+				{"Дата: 5.3.2024 г."},
+				{"Специалист (логопед):", "Сидорова"},
+				{"Фонематический слух", "3", "до 4"},
+			},
+			want: DiagnosticReport{
+				ChildID:    "Петров П.",
+				TestDate:   time.Date(2024, 3, 5, 0, 0, 0, 0, time.UTC),
+				Specialist: "Сидорова",
+				Indicators: []Indicator{{Name: "Фонематический слух", Score: 3, Norm: "до 4"}},
+			},
+		},
+		{
+			name:    "no indicators",
+			rows:    [][]string{{"Ребёнок: ID-0042"}, {"Показатель", "Балл"}, {"1", "2"}},
+			want:    DiagnosticReport{ChildID: "ID-0042"},
+			wantErr: ErrNoIndicators,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseReport([]Page{{Rows: tt.rows}})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("report = %+v\nwant %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func readTestdata(t *testing.T, name string) ([]Page, error) {
+	t.Helper()
+	f, err := os.Open(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = f.Close() }()
+	info, err := f.Stat()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ReadPages(context.Background(), f, info.Size())
+}
@@ -0,0 +1,595 @@
+package file
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+
+	"github.com/ledongthuc/pdf"
+)
+
+// Разбор текста страницы поверх объектной модели github.com/ledongthuc/pdf.
+// Библиотека читает структуру файла и потоки, но теряет координаты после Td
+// и неверно декодирует двухбайтовые ToUnicode-диапазоны (Identity-H), поэтому
+// операторы текста и CMap интерпретируются здесь.
+
+const (
+	// defaultGlyphWidth — ширина глифа в тысячных долях кегля, если шрифт её не указал
+	defaultGlyphWidth = 500
+	// rowTolerance — доля кегля, в пределах которой фрагменты считаются одной строкой
+	rowTolerance = 0.5
+	// wordGap и cellGap — доли кегля: больше wordGap — пробел между словами, больше cellGap — новая ячейка
+	wordGap = 0.15
+	cellGap = 2.0
+	// maxCID — наибольший CID, для которого храним ширину: коды Identity-H двухбайтовые.
+	// Значения из /W приходят из загруженного файла и без ограничения заняли бы всю память.
+	maxCID = 0xFFFF
+	// maxWidthEntries — сколько ширин из /W читаем для одного шрифта: диапазоны могут повторяться,
+	// и без общего ограничения тысячи записей "0 65535 w" стоили бы миллиардов записей в map
+	maxWidthEntries = maxCID + 1
+)
+
+// textRun — фрагмент текста, выведенный одним оператором показа строки
+type textRun struct {
+	x, y, endX float64
+	size       float64
+	s          string
+}
+
+// matrix — аффинное преобразование PDF [a b c d e f]
+type matrix [6]float64
+
+var identity = matrix{1, 0, 0, 1, 0, 0}
+
+func (m matrix) mul(n matrix) matrix {
+	return matrix{
+		m[0]*n[0] + m[1]*n[2],
+		m[0]*n[1] + m[1]*n[3],
+		m[2]*n[0] + m[3]*n[2],
+		m[2]*n[1] + m[3]*n[3],
+		m[4]*n[0] + m[5]*n[2] + n[4],
+		m[4]*n[1] + m[5]*n[3] + n[5],
+	}
+}
+
+func translate(tx, ty float64) matrix {
+	return matrix{1, 0, 0, 1, tx, ty}
+}
+
+// pdfFont — декодер кодов символов шрифта в Unicode и их ширины
+type pdfFont struct {
+	cmap     *toUnicode
+	fallback pdf.TextEncoding
+	codeLen  int
+	widths   map[int]float64
+	dw       float64
+}
+
+func newPDFFont(ctx context.Context, f pdf.Font) (*pdfFont, error) {
+	font := &pdfFont{widths: make(map[int]float64), dw: defaultGlyphWidth, codeLen: 1}
+
+	if f.V.Key("Subtype").Name() == "Type0" {
+		font.codeLen = 2
+		desc := f.V.Key("DescendantFonts").Index(0)
+		if dw := desc.Key("DW"); dw.Kind() != pdf.Null {
+			font.dw = dw.Float64()
+		} else {
+			font.dw = 1000
+		}
+		if err := readCIDWidths(ctx, desc.Key("W"), font.widths); err != nil {
+			return nil, err
+		}
+	} else {
+		first := f.FirstChar()
+		for i, w := range f.Widths() {
+			font.widths[first+i] = w
+		}
+	}
+
+	if tu := f.V.Key("ToUnicode"); tu.Kind() == pdf.Stream {
+		font.cmap = readToUnicode(tu)
+	}
+	if font.cmap == nil {
+		font.fallback = f.Encoder()
+	}
+	return font, nil
+}
+
+// readCIDWidths разбирает массив /W: "c [w1 w2 ...]" и "cFirst cLast w".
+// CID вне 0..maxCID и перевёрнутые диапазоны пропускаются, после maxWidthEntries ширин
+// остаток массива не читается.
+func readCIDWidths(ctx context.Context, w pdf.Value, out map[int]float64) error {
+	budget := maxWidthEntries
+	for i := 0; i < w.Len() && budget > 0; {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		first := w.Index(i).Int64()
+		next := w.Index(i + 1)
+		if next.Kind() == pdf.Array {
+			for j := 0; j < next.Len() && first >= 0 && first <= maxCID-int64(j) && budget > 0; j++ {
+				out[int(first)+j] = next.Index(j).Float64()
+				budget--
+			}
+			i += 2
+			continue
+		}
+		width := w.Index(i + 2).Float64()
+		if first >= 0 && first <= maxCID {
+			last := min(next.Int64(), maxCID, first+int64(budget)-1)
+			for c := first; c <= last; c++ {
+				out[int(c)] = width
+				budget--
+			}
+		}
+		i += 3
+	}
+	return nil
+}
+
+// decode разбивает строку на коды и возвращает текст и сдвиг по горизонтали в единицах текстового пространства
+func (f *pdfFont) decode(raw string, charSpace, wordSpace, size float64) (string, float64) {
+	var (
+		text    strings.Builder
+		advance float64
+	)
+	for len(raw) > 0 {
+		n := f.codeLen
+		if f.cmap != nil {
+			n = f.cmap.codeLen(raw)
+		}
+		n = min(n, len(raw))
+		code := raw[:n]
+		raw = raw[n:]
+
+		if f.cmap != nil {
+			text.WriteString(f.cmap.lookup(code))
+		} else {
+			text.WriteString(f.fallback.Decode(code))
+		}
+
+		w, ok := f.widths[codeValue(code)]
+		if !ok {
+			w = f.dw
+		}
+		advance += w/1000*size + charSpace
+		if n == 1 && code[0] == ' ' {
+			advance += wordSpace
+		}
+	}
+	return text.String(), advance
+}
+
+func codeValue(code string) int {
+	v := 0
+	for i := 0; i < len(code); i++ {
+		v = v<<8 | int(code[i])
+	}
+	return v
+}
+
+type codeRange struct {
+	lo, hi int
+	n      int
+}
+
+type unicodeRange struct {
+	lo, hi int
+	n      int
+	dst    []rune
+	array  []string
+}
+
+// toUnicode — CMap соответствия кодов шрифта символам Unicode
+type toUnicode struct {
+	space  []codeRange
+	chars  map[string]string
+	ranges []unicodeRange
+}
+
+func readToUnicode(v pdf.Value) *toUnicode {
+	data, err := io.ReadAll(v.Reader())
+	if err != nil {
+		return nil
+	}
+
+	m := &toUnicode{chars: make(map[string]string)}
+	var operands []cmapToken
+	for _, tok := range tokenizeCMap(data) {
+		if !tok.keyword {
+			operands = append(operands, tok)
+			continue
+		}
+		switch tok.text {
+		case "endcodespacerange":
+			for i := 0; i+1 < len(operands); i += 2 {
+				lo, hi := operands[i].text, operands[i+1].text
+				if len(lo) > 0 && len(lo) == len(hi) {
+					m.space = append(m.space, codeRange{lo: codeValue(lo), hi: codeValue(hi), n: len(lo)})
+				}
+			}
+		case "endbfchar":
+			for i := 0; i+1 < len(operands); i += 2 {
+				m.chars[operands[i].text] = utf16String(operands[i+1].text)
+			}
+		case "endbfrange":
+			for i := 0; i+2 < len(operands); i += 3 {
+				lo, hi, dst := operands[i].text, operands[i+1].text, operands[i+2]
+				r := unicodeRange{lo: codeValue(lo), hi: codeValue(hi), n: len(lo)}
+				if dst.array != nil {
+					for _, item := range dst.array {
+						r.array = append(r.array, utf16String(item))
+					}
+				} else {
+					r.dst = []rune(utf16String(dst.text))
+				}
+				m.ranges = append(m.ranges, r)
+			}
+		}
+		operands = operands[:0]
+	}
+	if len(m.chars) == 0 && len(m.ranges) == 0 {
+		return nil
+	}
+	return m
+}
+
+// cmapToken — шестнадцатеричная строка, массив таких строк или ключевое слово CMap
+type cmapToken struct {
+	text    string
+	array   []string
+	keyword bool
+}
+
+// tokenizeCMap выделяет из CMap только то, что нужно для соответствий кодов:
+// <hex>, [<hex> ...] и операторы; словари, имена и числа пропускаются
+func tokenizeCMap(data []byte) []cmapToken {
+	var (
+		tokens []cmapToken
+		array  []string
+		inArr  bool
+	)
+	for i := 0; i < len(data); {
+		c := data[i]
+		switch {
+		case c == '%':
+			for i < len(data) && data[i] != '\n' && data[i] != '\r' {
+				i++
+			}
+		case c == '<' && i+1 < len(data) && data[i+1] == '<':
+			i += 2
+		case c == '>' && i+1 < len(data) && data[i+1] == '>':
+			i += 2
+		case c == '<':
+			end := bytes.IndexByte(data[i:], '>')
+			if end < 0 {
+				return tokens
+			}
+			s := decodeHex(data[i+1 : i+end])
+			i += end + 1
+			if inArr {
+				array = append(array, s)
+			} else {
+				tokens = append(tokens, cmapToken{text: s})
+			}
+		case c == '[':
+			inArr, array = true, nil
+			i++
+		case c == ']':
+			if inArr {
+				tokens = append(tokens, cmapToken{array: append([]string{}, array...)})
+			}
+			inArr = false
+			i++
+		case c == '(':
+			// Строковые литералы встречаются только в /CIDSystemInfo
+			for depth := 0; i < len(data); i++ {
+				if data[i] == '\\' {
+					i++
+					continue
+				}
+				if data[i] == '(' {
+					depth++
+				} else if data[i] == ')' {
+					if depth--; depth == 0 {
+						i++
+						break
+					}
+				}
+			}
+		case isCMapSpace(c):
+			i++
+		default:
+			start := i
+			for i < len(data) && !isCMapSpace(data[i]) && !strings.ContainsRune("<>[]()%/", rune(data[i])) {
+				i++
+			}
+			if i == start {
+				// Имя: пропускаем "/" и сам идентификатор
+				i++
+				for i < len(data) && !isCMapSpace(data[i]) && !strings.ContainsRune("<>[]()%/", rune(data[i])) {
+					i++
+				}
+				continue
+			}
+			word := string(data[start:i])
+			if _, err := strconv.ParseFloat(word, 64); err != nil {
+				tokens = append(tokens, cmapToken{text: word, keyword: true})
+			}
+		}
+	}
+	return tokens
+}
+
+func isCMapSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f' || c == 0
+}
+
+func decodeHex(b []byte) string {
+	clean := make([]byte, 0, len(b))
+	for _, c := range b {
+		if !isCMapSpace(c) {
+			clean = append(clean, c)
+		}
+	}
+	if len(clean)%2 != 0 {
+		clean = append(clean, '0')
+	}
+	out, err := hex.DecodeString(string(clean))
+	if err != nil {
+		return ""
+	}
+	return string(out)
+}
+
+// codeLen — длина следующего кода по диапазонам codespacerange
+func (m *toUnicode) codeLen(raw string) int {
+	for n := 1; n <= 4 && n <= len(raw); n++ {
+		v := codeValue(raw[:n])
+		for _, r := range m.space {
+			if r.n == n && r.lo <= v && v <= r.hi {
+				return n
+			}
+		}
+	}
+	if len(m.space) > 0 {
+		return m.space[0].n
+	}
+	return 1
+}
+
+func (m *toUnicode) lookup(code string) string {
+	if s, ok := m.chars[code]; ok {
+		return s
+	}
+	v := codeValue(code)
+	for _, r := range m.ranges {
+		if r.n != len(code) || v < r.lo || v > r.hi {
+			continue
+		}
+		offset := v - r.lo
+		if r.array != nil {
+			if offset < len(r.array) {
+				return r.array[offset]
+			}
+			return ""
+		}
+		if len(r.dst) == 0 {
+			return ""
+		}
+		// Смещение прибавляется к последнему символу назначения целиком, а не к младшему байту
+		dst := append([]rune(nil), r.dst...)
+		dst[len(dst)-1] += rune(offset)
+		return string(dst)
+	}
+	return ""
+}
+
+func utf16String(raw string) string {
+	if len(raw)%2 != 0 {
+		return raw
+	}
+	units := make([]uint16, 0, len(raw)/2)
+	for i := 0; i < len(raw); i += 2 {
+		units = append(units, uint16(raw[i])<<8|uint16(raw[i+1]))
+	}
+	return string(utf16.Decode(units))
+}
+
+// pageRuns интерпретирует операторы текста страницы и возвращает фрагменты с координатами
+func pageRuns(ctx context.Context, p pdf.Page) ([]textRun, error) {
+	contents := p.V.Key("Contents")
+	if contents.Kind() == pdf.Null {
+		return nil, nil
+	}
+
+	fonts := make(map[string]*pdfFont)
+	for _, name := range p.Fonts() {
+		font, err := newPDFFont(ctx, p.Font(name))
+		if err != nil {
+			return nil, err
+		}
+		fonts[name] = font
+	}
+
+	var (
+		runs   []textRun
+		ctm    = identity
+		saved  []matrix
+		tm     = identity
+		tlm    = identity
+		font   *pdfFont
+		size   float64
+		lead   float64
+		cSpace float64
+		wSpace float64
+		hScale = 1.0
+	)
+
+	show := func(raw string) {
+		if font == nil {
+			return
+		}
+		s, advance := font.decode(raw, cSpace, wSpace, size)
+		start := tm.mul(ctm)
+		tm = translate(advance*hScale, 0).mul(tm)
+		end := tm.mul(ctm)
+		if strings.TrimSpace(s) == "" {
+			return
+		}
+		runs = append(runs, textRun{
+			x:    start[4],
+			y:    start[5],
+			endX: end[4],
+			size: size * math.Hypot(start[2], start[3]),
+			s:    s,
+		})
+	}
+	nextLine := func(tx, ty float64) {
+		tlm = translate(tx, ty).mul(tlm)
+		tm = tlm
+	}
+
+	pdf.Interpret(contents, func(stk *pdf.Stack, op string) {
+		args := make([]pdf.Value, stk.Len())
+		for i := len(args) - 1; i >= 0; i-- {
+			args[i] = stk.Pop()
+		}
+		num := func(i int) float64 {
+			if i < len(args) {
+				return args[i].Float64()
+			}
+			return 0
+		}
+
+		switch op {
+		case "q":
+			saved = append(saved, ctm)
+		case "Q":
+			if len(saved) > 0 {
+				ctm = saved[len(saved)-1]
+				saved = saved[:len(saved)-1]
+			}
+		case "cm":
+			ctm = matrix{num(0), num(1), num(2), num(3), num(4), num(5)}.mul(ctm)
+		case "BT":
+			tm, tlm = identity, identity
+		case "Tf":
+			if len(args) == 2 {
+				font = fonts[args[0].Name()]
+				size = num(1)
+			}
+		case "Tc":
+			cSpace = num(0)
+		case "Tw":
+			wSpace = num(0)
+		case "Tz":
+			hScale = num(0) / 100
+		case "TL":
+			lead = num(0)
+		case "Td":
+			nextLine(num(0), num(1))
+		case "TD":
+			lead = -num(1)
+			nextLine(num(0), num(1))
+		case "Tm":
+			tlm = matrix{num(0), num(1), num(2), num(3), num(4), num(5)}
+			tm = tlm
+		case "T*":
+			nextLine(0, -lead)
+		case "Tj":
+			if len(args) == 1 {
+				show(args[0].RawString())
+			}
+		case "'":
+			nextLine(0, -lead)
+			if len(args) == 1 {
+				show(args[0].RawString())
+			}
+		case "\"":
+			if len(args) == 3 {
+				wSpace, cSpace = num(0), num(1)
+				nextLine(0, -lead)
+				show(args[2].RawString())
+			}
+		case "TJ":
+			if len(args) != 1 {
+				return
+			}
+			arr := args[0]
+			for i := 0; i < arr.Len(); i++ {
+				item := arr.Index(i)
+				if item.Kind() == pdf.String {
+					show(item.RawString())
+					continue
+				}
+				tm = translate(-item.Float64()/1000*size*hScale, 0).mul(tm)
+			}
+		}
+	})
+	return runs, nil
+}
+
+// layoutRows группирует фрагменты в строки сверху вниз и склеивает соседние фрагменты в ячейки
+func layoutRows(runs []textRun) [][]string {
+	sort.SliceStable(runs, func(i, j int) bool { return runs[i].y > runs[j].y })
+
+	var lines [][]textRun
+	for _, run := range runs {
+		if n := len(lines); n > 0 {
+			last := lines[n-1][0]
+			if math.Abs(last.y-run.y) <= rowTolerance*math.Max(last.size, run.size) {
+				lines[n-1] = append(lines[n-1], run)
+				continue
+			}
+		}
+		lines = append(lines, []textRun{run})
+	}
+
+	rows := make([][]string, 0, len(lines))
+	for _, line := range lines {
+		sort.SliceStable(line, func(i, j int) bool { return line[i].x < line[j].x })
+
+		var (
+			cells []string
+			cell  strings.Builder
+		)
+		for i, run := range line {
+			if i > 0 {
+				gap := run.x - line[i-1].endX
+				em := math.Max(run.size, 1)
+				switch {
+				case gap > cellGap*em:
+					cells = append(cells, cell.String())
+					cell.Reset()
+				case gap > wordGap*em:
+					cell.WriteByte(' ')
+				}
+			}
+			cell.WriteString(run.s)
+		}
+		cells = append(cells, cell.String())
+
+		// Таблицы, набранные одной строкой, разделяем по широким пробелам
+		if len(cells) == 1 {
+			cells = spacesRe.Split(cells[0], -1)
+		}
+
+		cleaned := cells[:0]
+		for _, c := range cells {
+			if c = strings.Join(strings.Fields(c), " "); c != "" {
+				cleaned = append(cleaned, c)
+			}
+		}
+		if len(cleaned) > 0 {
+			rows = append(rows, cleaned)
+		}
+	}
+	return rows
+}
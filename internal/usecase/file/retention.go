@@ -0,0 +1,128 @@
+package file
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/Caritas-Team/reviewer/internal/config"
+	"github.com/Caritas-Team/reviewer/internal/storage"
+	"github.com/google/uuid"
+)
+
+// maxRetentionInterval — верхняя граница паузы между проверками каталога
+const maxRetentionInterval = 10 * time.Minute
+
+// operationFileSuffixes — файлы операции: загруженный PDF, извлечённые данные и готовое заключение
+var operationFileSuffixes = []string{".pdf", ".json", ".report.pdf"}
+
+// Retention удаляет файлы операций, записи которых истекли: без записи файлы недоступны
+// через API и только занимают диск
+type Retention struct {
+	dir        string
+	ttl        time.Duration
+	operations storage.OperationStore
+}
+
+// NewRetention удаляет файлы из files.storage_dir вслед за записями операций, живущими memcached.default_ttl
+func NewRetention(cfg config.Config, operations storage.OperationStore) *Retention {
+	return &Retention{
+		dir:        cfg.Files.Dir(),
+		ttl:        time.Duration(cfg.Memcached.DefaultTTL) * time.Second,
+		operations: operations,
+	}
+}
+
+// Run проверяет каталог раз в default_ttl, но не реже чем раз в 10 минут. С default_ttl: 0
+// записи не истекают, и Run сразу возвращается. Возвращается после отмены ctx.
+func (r *Retention) Run(ctx context.Context) {
+	if r.ttl <= 0 {
+		return
+	}
+	ticker := time.NewTicker(min(r.ttl, maxRetentionInterval))
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		removed, err := r.Sweep(ctx, time.Now())
+		if removed > 0 {
+			slog.Info("expired operation files removed", "count", removed)
+		}
+		if err != nil && ctx.Err() == nil {
+			slog.Warn("expired operation files cleanup failed", "dir", r.dir, "err", err)
+		}
+	}
+}
+
+// Sweep удаляет файлы операций, которых нет в хранилище, если ни один файл операции не менялся
+// дольше TTL: так не удаляются файлы загрузки, запись которой ещё не сохранена. Файлы с чужими
+// именами не трогает. Возвращает число удалённых файлов.
+func (r *Retention) Sweep(ctx context.Context, now time.Time) (int, error) {
+	entries, err := os.ReadDir(r.dir)
+	if err != nil {
+		return 0, fmt.Errorf("read storage dir: %w", err)
+	}
+
+	files := make(map[string][]string)
+	updated := make(map[string]time.Time)
+	for _, entry := range entries {
+		id, ok := operationFile(entry.Name())
+		if !ok || !entry.Type().IsRegular() {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		files[id] = append(files[id], entry.Name())
+		if info.ModTime().After(updated[id]) {
+			updated[id] = info.ModTime()
+		}
+	}
+
+	removed := 0
+	var errs []error
+	for id, names := range files {
+		if now.Sub(updated[id]) < r.ttl {
+			continue
+		}
+		_, err := r.operations.Get(ctx, id)
+		if err == nil {
+			continue
+		}
+		// Недоступное хранилище не значит, что операция истекла
+		if !errors.Is(err, storage.ErrNotFound) {
+			return removed, errors.Join(append(errs, fmt.Errorf("operation %s: %w", id, err))...)
+		}
+		for _, name := range names {
+			if err := os.Remove(filepath.Join(r.dir, name)); err != nil && !errors.Is(err, os.ErrNotExist) {
+				errs = append(errs, err)
+				continue
+			}
+			removed++
+		}
+	}
+	return removed, errors.Join(errs...)
+}
+
+// operationFile возвращает идентификатор операции, если файл назван так, как его сохраняют
+// загрузка и обработчики: <uuid>.pdf, <uuid>.json, <uuid>.report.pdf
+func operationFile(name string) (string, bool) {
+	id, _, _ := strings.Cut(name, ".")
+	for _, suffix := range operationFileSuffixes {
+		if name == id+suffix {
+			_, err := uuid.Parse(id)
+			return id, err == nil
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,76 @@
+package file
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/Caritas-Team/reviewer/internal/config"
+	"github.com/Caritas-Team/reviewer/internal/storage"
+	"github.com/Caritas-Team/reviewer/internal/storage/storagetest"
+	"github.com/google/uuid"
+)
+
+func TestRetentionSweep(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	store := storagetest.NewMemoryStore(t)
+	r := NewRetention(config.Config{
+		Files:     config.Files{StorageDir: dir},
+		Memcached: config.Memcached{DefaultTTL: 60},
+	}, store)
+
+	now := time.Now()
+	old := now.Add(-2 * time.Minute)
+	create := func(name string, modified time.Time) {
+		t.Helper()
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, nil, 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, modified, modified); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	expired, live, uploading, finishing := uuid.NewString(), uuid.NewString(), uuid.NewString(), uuid.NewString()
+	for _, name := range []string{expired + ".pdf", expired + ".json", expired + ".report.pdf"} {
+		create(name, old)
+	}
+	create(live+".pdf", old)
+	if _, err := storage.SetStatus(ctx, store, storage.Operation{ID: live}, storage.StatusDone, ""); err != nil {
+		t.Fatal(err)
+	}
+	// Запись загрузки ещё не сохранена, а у завершения свежий только результат
+	create(uploading+".pdf", now)
+	create(finishing+".pdf", old)
+	create(finishing+".report.pdf", now)
+	// Чужие файлы в каталоге не удаляются
+	create("notes.pdf", old)
+	create(expired+".txt", old)
+
+	removed, err := r.Sweep(ctx, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if removed != 3 {
+		t.Errorf("removed %d files, want 3", removed)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	want := []string{live + ".pdf", uploading + ".pdf", finishing + ".pdf", finishing + ".report.pdf", "notes.pdf", expired + ".txt"}
+	slices.Sort(names)
+	slices.Sort(want)
+	if !slices.Equal(names, want) {
+		t.Errorf("files left = %q, want %q", names, want)
+	}
+}
@@ -1 +1,270 @@
 package file
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Caritas-Team/reviewer/internal/config"
+	"github.com/Caritas-Team/reviewer/internal/logger"
+	"github.com/Caritas-Team/reviewer/internal/metrics"
+	"github.com/Caritas-Team/reviewer/internal/storage"
+)
+
+const (
+	// defaultQueueSize — ёмкость очереди, если queue_size не задан
+	defaultQueueSize = 100
+
+	// ShutdownReason — текст ошибки операций, прерванных остановкой сервиса
+	ShutdownReason = "shutdown"
+
+	// shutdownGrace — сколько Shutdown ждёт прерванные задачи после истечения ctx
+	shutdownGrace = time.Second
+)
+
+var (
+	ErrQueueFull        = errors.New("processing queue is full")
+	ErrSchedulerStopped = errors.New("scheduler is stopped")
+)
+
+// Processor обрабатывает файл операции и возвращает её с заполненными путями к результатам
+type Processor interface {
+	Process(ctx context.Context, op storage.Operation) (storage.Operation, error)
+}
+
+// ProcessorFunc позволяет использовать обычную функцию как Processor
+type ProcessorFunc func(ctx context.Context, op storage.Operation) (storage.Operation, error)
+
+func (f ProcessorFunc) Process(ctx context.Context, op storage.Operation) (storage.Operation, error) {
+	return f(ctx, op)
+}
+
+// Chain выполняет обработчики по очереди, передавая каждому операцию, дополненную предыдущим
+func Chain(processors ...Processor) Processor {
+	return ProcessorFunc(func(ctx context.Context, op storage.Operation) (storage.Operation, error) {
+		for _, p := range processors {
+			var err error
+			if op, err = p.Process(ctx, op); err != nil {
+				return op, err
+			}
+		}
+		return op, nil
+	})
+}
+
+type job struct {
+	op         storage.Operation
+	enqueuedAt time.Time
+}
+
+// Scheduler — пул воркеров, ограничивающий число одновременно обрабатываемых файлов
+type Scheduler struct {
+	store     storage.OperationStore
+	processor Processor
+	workers   int
+	timeout   time.Duration
+
+	queue      chan job
+	inProgress atomic.Int64
+	wg         sync.WaitGroup
+
+	// cancel прерывает текущие задачи, aborted — оставшиеся в очереди не обрабатываются
+	cancel  context.CancelFunc
+	aborted atomic.Bool
+	grace   time.Duration
+
+	// active — операции в обработке: их помечает Shutdown, если обработчик не вернулся
+	activeMu sync.Mutex
+	active   map[string]storage.Operation
+
+	mu      sync.RWMutex
+	stopped bool
+}
+
+// NewScheduler создаёт планировщик; воркеры запускаются методом Start
+func NewScheduler(cfg config.Files, store storage.OperationStore, processor Processor) *Scheduler {
+	workers := cfg.Workers
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+	queueSize := cfg.QueueSize
+	if queueSize <= 0 {
+		queueSize = defaultQueueSize
+	}
+	return &Scheduler{
+		store:     store,
+		processor: processor,
+		workers:   workers,
+		timeout:   cfg.ProcessingTimeout(),
+		queue:     make(chan job, queueSize),
+		grace:     shutdownGrace,
+		active:    make(map[string]storage.Operation),
+	}
+}
+
+// Start запускает воркеры; ctx ограничивает время жизни всех задач
+func (s *Scheduler) Start(ctx context.Context) {
+	ctx, s.cancel = context.WithCancel(ctx)
+	for i := 0; i < s.workers; i++ {
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			for j := range s.queue {
+				s.run(ctx, j)
+			}
+		}()
+	}
+}
+
+// Submit ставит операцию в очередь, не блокируясь; при переполнении возвращает ErrQueueFull
+func (s *Scheduler) Submit(op storage.Operation) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.stopped {
+		return ErrSchedulerStopped
+	}
+	select {
+	case s.queue <- job{op: op, enqueuedAt: time.Now()}:
+		metrics.UpdateQueueLength(float64(len(s.queue)))
+		return nil
+	default:
+		return ErrQueueFull
+	}
+}
+
+// Shutdown перестаёт принимать задачи и ждёт, пока воркеры разберут очередь.
+// Если ctx истёк раньше, текущие задачи прерываются, а они и ещё не начатые
+// получают статус ERROR с причиной ShutdownReason. Обработчик, не следящий за
+// контекстом, держит остановку не дольше shutdownGrace: его операция помечается
+// без ожидания.
+func (s *Scheduler) Shutdown(ctx context.Context) error {
+	s.mu.Lock()
+	if !s.stopped {
+		s.stopped = true
+		close(s.queue)
+	}
+	s.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+	}
+
+	s.aborted.Store(true)
+	if s.cancel != nil {
+		s.cancel()
+	}
+	grace := time.NewTimer(s.grace)
+	defer grace.Stop()
+	select {
+	case <-done:
+		return ctx.Err()
+	case <-grace.C:
+	}
+
+	// Воркеры заняты зависшими обработчиками: оставшуюся очередь и их операции помечаем сами
+	storeCtx := context.WithoutCancel(ctx)
+	for j := range s.queue {
+		s.fail(storeCtx, j.op, ShutdownReason)
+	}
+	s.activeMu.Lock()
+	stuck := make([]storage.Operation, 0, len(s.active))
+	for _, op := range s.active {
+		stuck = append(stuck, op)
+	}
+	s.activeMu.Unlock()
+	for _, op := range stuck {
+		slog.WarnContext(storeCtx, "operation did not stop in time", "id", op.ID)
+		s.fail(storeCtx, op, ShutdownReason)
+	}
+	return ctx.Err()
+}
+
+func (s *Scheduler) run(ctx context.Context, j job) {
+	metrics.UpdateQueueLength(float64(len(s.queue)))
+
+	// Логи воркера связываются с HTTP-запросом, создавшим операцию
+	ctx = logger.WithRequestID(ctx, j.op.RequestID)
+
+	// Статусы записываем и после отмены: иначе прерванные операции навсегда останутся в PROGRESS
+	storeCtx := context.WithoutCancel(ctx)
+	if s.aborted.Load() {
+		s.fail(storeCtx, j.op, ShutdownReason)
+		return
+	}
+	metrics.UpdateWorkerQueueDelay(time.Since(j.enqueuedAt).Seconds())
+
+	metrics.UpdateCurrentFilesInProgress(float64(s.inProgress.Add(1)))
+	defer func() {
+		metrics.UpdateCurrentFilesInProgress(float64(s.inProgress.Add(-1)))
+	}()
+
+	op, err := storage.SetStatus(storeCtx, s.store, j.op, storage.StatusProgress, "")
+	if err != nil {
+		slog.ErrorContext(ctx, "operation status update failed", "id", op.ID, "status", storage.StatusProgress, "err", err)
+		return
+	}
+	s.activeMu.Lock()
+	s.active[op.ID] = op
+	s.activeMu.Unlock()
+	defer func() {
+		s.activeMu.Lock()
+		delete(s.active, op.ID)
+		s.activeMu.Unlock()
+	}()
+
+	jobCtx := ctx
+	if s.timeout > 0 {
+		var cancel context.CancelFunc
+		jobCtx, cancel = context.WithTimeout(ctx, s.timeout)
+		defer cancel()
+	}
+
+	slog.DebugContext(ctx, "operation started", "id", op.ID)
+	start := time.Now()
+	processed, err := s.processor.Process(jobCtx, op)
+	if err == nil {
+		// Процессор мог не следить за контекстом — результат после дедлайна не принимаем
+		err = jobCtx.Err()
+	}
+	duration := time.Since(start).Seconds()
+
+	if err != nil {
+		reason := err.Error()
+		switch {
+		case s.aborted.Load():
+			reason = ShutdownReason
+		case errors.Is(err, context.DeadlineExceeded):
+			reason = fmt.Sprintf("processing exceeded %s", s.timeout)
+		}
+		metrics.UpdateFileProcessingTime("error", duration)
+		slog.WarnContext(ctx, "operation failed", "id", op.ID, "err", err)
+		s.fail(storeCtx, op, reason)
+		return
+	}
+
+	metrics.UpdateFileProcessingTime("success", duration)
+	slog.DebugContext(ctx, "operation processed", "id", op.ID, "duration", duration)
+	if _, err := storage.SetStatus(storeCtx, s.store, processed, storage.StatusDone, ""); err != nil {
+		slog.ErrorContext(ctx, "operation status update failed", "id", op.ID, "status", storage.StatusDone, "err", err)
+	}
+}
+
+func (s *Scheduler) fail(ctx context.Context, op storage.Operation, reason string) {
+	if _, err := storage.SetStatus(ctx, s.store, op, storage.StatusError, reason); err != nil {
+		slog.ErrorContext(ctx, "operation status update failed", "id", op.ID, "status", storage.StatusError, "err", err)
+	}
+}
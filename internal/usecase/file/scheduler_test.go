@@ -0,0 +1,99 @@
+package file
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/Caritas-Team/reviewer/internal/config"
+	"github.com/Caritas-Team/reviewer/internal/storage"
+	"github.com/Caritas-Team/reviewer/internal/storage/storagetest"
+)
+
+func TestSchedulerShutdownDrains(t *testing.T) {
+	store := storagetest.NewMemoryStore(t)
+	s := NewScheduler(config.Files{Workers: 2}, store, ProcessorFunc(func(ctx context.Context, op storage.Operation) (storage.Operation, error) {
+		op.ResultPath = op.FilePath + ".out"
+		return op, nil
+	}))
+	s.Start(context.Background())
+
+	ids := submitOps(t, s, store, 5)
+	if err := s.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range ids {
+		assertStatus(t, store, id, storage.StatusDone, "")
+	}
+	if err := s.Submit(storage.Operation{ID: "late"}); !errors.Is(err, ErrSchedulerStopped) {
+		t.Errorf("Submit after Shutdown: err = %v, want ErrSchedulerStopped", err)
+	}
+}
+
+// Обработчик, не следящий за контекстом, не должен держать остановку сервиса
+func TestSchedulerShutdownStuckProcessor(t *testing.T) {
+	store := storagetest.NewMemoryStore(t)
+	started := make(chan struct{}, 1)
+	release := make(chan struct{})
+	t.Cleanup(func() { close(release) })
+
+	s := NewScheduler(config.Files{Workers: 1}, store, ProcessorFunc(func(ctx context.Context, op storage.Operation) (storage.Operation, error) {
+		started <- struct{}{}
+		<-release
+		return op, nil
+	}))
+	s.grace = 10 * time.Millisecond
+	s.Start(context.Background())
+
+	ids := submitOps(t, s, store, 3)
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	returned := make(chan error, 1)
+	go func() { returned <- s.Shutdown(ctx) }()
+
+	select {
+	case err := <-returned:
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("Shutdown: err = %v, want DeadlineExceeded", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Shutdown is blocked by a processor that ignores ctx")
+	}
+	for _, id := range ids {
+		assertStatus(t, store, id, storage.StatusError, ShutdownReason)
+	}
+}
+
+func submitOps(t *testing.T, s *Scheduler, store storage.OperationStore, n int) []string {
+	t.Helper()
+	ids := make([]string, n)
+	for i := range ids {
+		op, err := storage.SetStatus(context.Background(), store, storage.Operation{
+			ID:       "op-" + strconv.Itoa(i),
+			FilePath: "file-" + strconv.Itoa(i) + ".pdf",
+		}, storage.StatusNew, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := s.Submit(op); err != nil {
+			t.Fatal(err)
+		}
+		ids[i] = op.ID
+	}
+	return ids
+}
+
+func assertStatus(t *testing.T, store storage.OperationStore, id string, status storage.Status, reason string) {
+	t.Helper()
+	op, err := store.Get(context.Background(), id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if op.Status != status || op.Error != reason {
+		t.Errorf("operation %s: status %s (%q), want %s (%q)", id, op.Status, op.Error, status, reason)
+	}
+}
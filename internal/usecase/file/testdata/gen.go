@@ -0,0 +1,152 @@
+//go:build ignore
+
+// Генератор синтетических PDF для тестов извлечения: go run gen.go .
+// Шрифты без встроенных глифов — разбору текста нужны только коды, /W и ToUnicode.
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// cell — фрагмент текста в точке (x, y) пространства страницы
+type cell struct {
+	x, y float64
+	s    string
+}
+
+// cid кодирует символ так, как его раскладывает ToUnicode из cmap: ASCII как есть,
+// кириллица со смещением 0x100, «≥» отдельной записью bfchar
+func cid(r rune) int {
+	switch {
+	case r < 0x80:
+		return int(r)
+	case r >= 0x400 && r < 0x500:
+		return int(r-0x400) + 0x100
+	case r == '≥':
+		return 0x300
+	}
+	panic(string(r))
+}
+
+func hexCIDs(s string) string {
+	var b strings.Builder
+	b.WriteByte('<')
+	for _, r := range s {
+		fmt.Fprintf(&b, "%04X", cid(r))
+	}
+	b.WriteByte('>')
+	return b.String()
+}
+
+func build(objs []string) []byte {
+	var buf bytes.Buffer
+	buf.WriteString("%PDF-1.4\n")
+	offs := make([]int, len(objs))
+	for i, o := range objs {
+		offs[i] = buf.Len()
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", i+1, o)
+	}
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(objs)+1)
+	for _, o := range offs {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", o)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objs)+1, xref)
+	return buf.Bytes()
+}
+
+func stream(s string) string {
+	return fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", len(s), s)
+}
+
+const cmap = `/CIDInit /ProcSet findresource begin
+12 dict begin
+begincmap
+/CIDSystemInfo << /Registry (Adobe) /Ordering (UCS) /Supplement 0 >> def
+/CMapName /Adobe-Identity-UCS def
+/CMapType 2 def
+1 begincodespacerange
+<0000> <FFFF>
+endcodespacerange
+2 beginbfrange
+<0020> <007E> <0020>
+<0100> <01FF> <0400>
+endbfrange
+1 beginbfchar
+<0300> <2265>
+endbfchar
+endcmap
+CMapName currentdict /CMap defineresource pop
+end
+end`
+
+func identityPDF(cells []cell, widths string) []byte {
+	var c strings.Builder
+	c.WriteString("BT\n/F1 11 Tf\n")
+	for _, cl := range cells {
+		fmt.Fprintf(&c, "1 0 0 1 %g %g Tm %s Tj\n", cl.x, cl.y, hexCIDs(cl.s))
+	}
+	c.WriteString("ET")
+	return build([]string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		"<< /Type /Page /Parent 2 0 R /MediaBox [0 0 595 842] /Resources << /Font << /F1 5 0 R >> >> /Contents 4 0 R >>",
+		stream(c.String()),
+		"<< /Type /Font /Subtype /Type0 /BaseFont /Synthetic /Encoding /Identity-H /DescendantFonts [6 0 R] /ToUnicode 7 0 R >>",
+		"<< /Type /Font /Subtype /CIDFontType2 /BaseFont /Synthetic /CIDSystemInfo << /Registry (Adobe) /Ordering (Identity) /Supplement 0 >> /DW 1000 /W " + widths + " >>",
+		stream(cmap),
+	})
+}
+
+func helveticaPDF(cells []cell) []byte {
+	var c strings.Builder
+	c.WriteString("BT\n/F1 11 Tf\n")
+	for _, cl := range cells {
+		fmt.Fprintf(&c, "1 0 0 1 %g %g Tm (%s) Tj\n", cl.x, cl.y, cl.s)
+	}
+	c.WriteString("ET")
+	return build([]string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		"<< /Type /Page /Parent 2 0 R /MediaBox [0 0 595 842] /Resources << /Font << /F1 5 0 R >> >> /Contents 4 0 R >>",
+		stream(c.String()),
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>",
+	})
+}
+
+func main() {
+	dir := os.Args[1]
+	report := []cell{
+		{50, 780, "Заключение по результатам диагностики"},
+		{50, 760, "Ребёнок: ID-0042"},
+		{50, 745, "Дата обследования: 10.01.2025"},
+		{50, 730, "Специалист: Иванова А. А."},
+		{50, 700, "Показатель"}, {250, 700, "Балл"}, {330, 700, "Норма"},
+		{50, 685, "Внимание"}, {250, 685, "12"}, {330, 685, "10-15"},
+		{50, 670, "Память"}, {250, 670, "8,5"}, {330, 670, "≥ 9"},
+		{50, 655, "Речь"}, {250, 655, "14"},
+	}
+	write := func(name string, data []byte) {
+		if err := os.WriteFile(dir+"/"+name, data, 0o644); err != nil {
+			panic(err)
+		}
+	}
+	identity := identityPDF(report, "[32 126 550 256 511 600 768 [600]]")
+	write("identity-h.pdf", identity)
+	write("huge-widths.pdf", identityPDF(report, "[0 2147483647 500 9223372036854775000 [500 500] -5 [500] 4294967296 4294967300 500]"))
+	// Повторяющиеся полные диапазоны: каждый в пределах maxCID, но вместе — миллиарды записей
+	write("repeated-widths.pdf", identityPDF(report, "["+strings.Repeat("0 65535 500 ", 2000)+"]"))
+	write("split-labels.pdf", helveticaPDF([]cell{
+		{50, 780, "Child"}, {120, 780, "ID:"}, {200, 780, "C-17"},
+		{50, 765, "Test"}, {120, 765, "date:"}, {200, 765, "2025-03-01"},
+		{50, 750, "Specialist:"}, {200, 750, "J. Smith"},
+		{50, 720, "Attention"}, {250, 720, "12"}, {330, 720, "10-15"},
+		{50, 705, "Memory"}, {250, 705, "8.5"}, {330, 705, ">= 9"},
+	}))
+	write("truncated.pdf", identity[:len(identity)/2])
+	write("broken-xref.pdf", bytes.Replace(identity, []byte("startxref\n"), []byte("startxref\n7"), 1))
+	write("not-pdf.pdf", []byte("Показатель;Балл\nВнимание;12\n"))
+}
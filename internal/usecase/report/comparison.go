@@ -0,0 +1,222 @@
+package report
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Caritas-Team/reviewer/internal/metrics"
+	"github.com/Caritas-Team/reviewer/internal/usecase/file"
+)
+
+// epsilon — разница баллов, которую считаем отсутствием изменений
+const epsilon = 1e-9
+
+var (
+	ErrNotEnoughReports = errors.New("at least two reports are required for comparison")
+	ErrDifferentChild   = errors.New("reports belong to different children")
+)
+
+// Trend — направление изменения показателя
+type Trend string
+
+const (
+	TrendUp   Trend = "up"
+	TrendDown Trend = "down"
+	TrendSame Trend = "same"
+)
+
+// Delta — изменение показателя между двумя заключениями
+type Delta struct {
+	From     float64  `json:"from"`
+	To       float64  `json:"to"`
+	Absolute float64  `json:"absolute"`
+	Percent  *float64 `json:"percent"` // nil, если исходный балл равен нулю
+	Trend    Trend    `json:"trend"`
+}
+
+// IndicatorComparison — показатель, выровненный по всем заключениям
+type IndicatorComparison struct {
+	Name string `json:"name"`
+	// Scores[i] — балл в заключении Dates[i], nil — показатель в нём отсутствует
+	Scores []*float64 `json:"scores"`
+	// Steps — изменения между соседними заключениями, где показатель есть
+	Steps []Delta `json:"steps"`
+	// Total — изменение от первого до последнего измерения, nil при одном измерении
+	Total *Delta `json:"total"`
+}
+
+// Comparison — результат сравнения "до/после" по одному ребёнку
+type Comparison struct {
+	ChildID     string                `json:"child_id"`
+	Dates       []time.Time           `json:"dates"`
+	Indicators  []IndicatorComparison `json:"indicators"`
+	Conclusions []string              `json:"conclusions"`
+}
+
+// Compare выравнивает показатели двух и более заключений по названию,
+// считает абсолютные и процентные изменения и формирует текстовые выводы
+func Compare(reports []file.DiagnosticReport, lang Language) (cmp Comparison, err error) {
+	start := time.Now()
+	defer func() {
+		metrics.UpdateComparisonTime(time.Since(start).Seconds())
+		if err != nil {
+			metrics.UpdateComparisonError()
+			return
+		}
+		metrics.UpdateComparisonSuccess()
+	}()
+
+	if len(reports) < 2 {
+		return cmp, ErrNotEnoughReports
+	}
+	for _, r := range reports[1:] {
+		if r.ChildID != reports[0].ChildID {
+			return cmp, ErrDifferentChild
+		}
+	}
+
+	sorted := append([]file.DiagnosticReport(nil), reports...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].TestDate.Before(sorted[j].TestDate) })
+
+	cmp.ChildID = sorted[0].ChildID
+	index := make(map[string]int)
+	for i, r := range sorted {
+		cmp.Dates = append(cmp.Dates, r.TestDate)
+		for _, ind := range r.Indicators {
+			key := normalizeName(ind.Name)
+			pos, ok := index[key]
+			if !ok {
+				pos = len(cmp.Indicators)
+				index[key] = pos
+				cmp.Indicators = append(cmp.Indicators, IndicatorComparison{
+					Name:   ind.Name,
+					Scores: make([]*float64, len(sorted)),
+				})
+			}
+			score := ind.Score
+			cmp.Indicators[pos].Scores[i] = &score
+		}
+	}
+
+	for i := range cmp.Indicators {
+		ic := &cmp.Indicators[i]
+		var first, prev *float64
+		for _, score := range ic.Scores {
+			if score == nil {
+				continue
+			}
+			if prev != nil {
+				ic.Steps = append(ic.Steps, newDelta(*prev, *score))
+			} else {
+				first = score
+			}
+			prev = score
+		}
+		if first != nil && prev != first {
+			total := newDelta(*first, *prev)
+			ic.Total = &total
+		}
+		cmp.Conclusions = append(cmp.Conclusions, conclusion(*ic, lang))
+	}
+	return cmp, nil
+}
+
+func newDelta(from, to float64) Delta {
+	d := Delta{From: from, To: to, Absolute: to - from, Trend: TrendSame}
+	switch {
+	case d.Absolute > epsilon:
+		d.Trend = TrendUp
+	case d.Absolute < -epsilon:
+		d.Trend = TrendDown
+	}
+	if math.Abs(from) > epsilon {
+		p := d.Absolute / math.Abs(from) * 100
+		d.Percent = &p
+	}
+	return d
+}
+
+// normalizeName сопоставляет названия, записанные с разным регистром, пробелами и "ё"
+func normalizeName(name string) string {
+	name = strings.ToLower(strings.Join(strings.Fields(name), " "))
+	return strings.ReplaceAll(name, "ё", "е")
+}
+
+// Language — язык текстовых выводов
+type Language string
+
+const (
+	LanguageRU Language = "ru"
+	LanguageEN Language = "en"
+)
+
+// ParseLanguage возвращает поддерживаемый язык, по умолчанию русский
+func ParseLanguage(s string) Language {
+	if Language(strings.ToLower(s)) == LanguageEN {
+		return LanguageEN
+	}
+	return LanguageRU
+}
+
+type phrases struct {
+	increased, decreased, unchanged, single string
+	decimal                                 byte
+}
+
+var messages = map[Language]phrases{
+	LanguageRU: {
+		increased: "Показатель «%s» увеличился на %s",
+		decreased: "Показатель «%s» уменьшился на %s",
+		unchanged: "Показатель «%s» не изменился (%s)",
+		single:    "Показатель «%s» измерен только один раз",
+		decimal:   ',',
+	},
+	LanguageEN: {
+		increased: "Indicator \"%s\" increased by %s",
+		decreased: "Indicator \"%s\" decreased by %s",
+		unchanged: "Indicator \"%s\" did not change (%s)",
+		single:    "Indicator \"%s\" was measured only once",
+		decimal:   '.',
+	},
+}
+
+// conclusion формирует вывод вида "Показатель X увеличился на Y% (с A до B)"
+func conclusion(ic IndicatorComparison, lang Language) string {
+	p, ok := messages[lang]
+	if !ok {
+		p = messages[LanguageRU]
+	}
+	if ic.Total == nil {
+		return fmt.Sprintf(p.single, ic.Name)
+	}
+
+	d := ic.Total
+	if d.Trend == TrendSame {
+		return fmt.Sprintf(p.unchanged, ic.Name, FormatNumber(d.To, p.decimal))
+	}
+
+	amount := FormatNumber(math.Abs(d.Absolute), p.decimal)
+	if d.Percent != nil {
+		amount = FormatNumber(math.Abs(*d.Percent), p.decimal) + "%"
+	}
+	amount += fmt.Sprintf(" (%s → %s)", FormatNumber(d.From, p.decimal), FormatNumber(d.To, p.decimal))
+
+	if d.Trend == TrendUp {
+		return fmt.Sprintf(p.increased, ic.Name, amount)
+	}
+	return fmt.Sprintf(p.decreased, ic.Name, amount)
+}
+
+// FormatNumber округляет до десятых и убирает лишний ноль: 12, 12,5
+func FormatNumber(v float64, decimal byte) string {
+	s := strconv.FormatFloat(math.Round(v*10)/10, 'f', -1, 64)
+	if decimal != '.' {
+		s = strings.Replace(s, ".", string(decimal), 1)
+	}
+	return s
+}
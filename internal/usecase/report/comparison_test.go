@@ -0,0 +1,111 @@
+package report
+
+import (
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/Caritas-Team/reviewer/internal/usecase/file"
+)
+
+func day(d int) time.Time {
+	return time.Date(2025, 1, d, 0, 0, 0, 0, time.UTC)
+}
+
+func TestCompare(t *testing.T) {
+	// Заключения переданы не по порядку дат; названия записаны по-разному
+	reports := []file.DiagnosticReport{
+		{ChildID: "c", TestDate: day(20), Indicators: []file.Indicator{
+			{Name: "Внимание", Score: 15}, {Name: "Речь", Score: 4}, {Name: "Моторика", Score: 3},
+		}},
+		{ChildID: "c", TestDate: day(1), Indicators: []file.Indicator{
+			{Name: "Внимание", Score: 10}, {Name: "Речь", Score: 0}, {Name: "Зрительное  восприятие", Score: 7},
+		}},
+		{ChildID: "c", TestDate: day(10), Indicators: []file.Indicator{
+			{Name: "внимание", Score: 12}, {Name: "Зрительное восприятие", Score: 7}, {Name: "Память", Score: 5},
+		}},
+	}
+
+	cmp, err := Compare(reports, LanguageRU)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(cmp.Dates, []time.Time{day(1), day(10), day(20)}) {
+		t.Fatalf("dates = %v, want sorted", cmp.Dates)
+	}
+	names := make([]string, len(cmp.Indicators))
+	for i, ic := range cmp.Indicators {
+		names[i] = ic.Name
+	}
+	if want := []string{"Внимание", "Речь", "Зрительное  восприятие", "Память", "Моторика"}; !slices.Equal(names, want) {
+		t.Fatalf("indicators = %q, want %q", names, want)
+	}
+
+	attention := cmp.Indicators[0]
+	if len(attention.Steps) != 2 || attention.Steps[0].Absolute != 2 || attention.Steps[1].Absolute != 3 {
+		t.Errorf("attention steps = %+v", attention.Steps)
+	}
+	if attention.Total == nil || attention.Total.Trend != TrendUp || *attention.Total.Percent != 50 {
+		t.Errorf("attention total = %+v, want +50%%", attention.Total)
+	}
+
+	// Показатель пропущен в среднем заключении: шаг считается между соседними измерениями
+	speech := cmp.Indicators[1]
+	if speech.Scores[1] != nil || len(speech.Steps) != 1 || speech.Total.Percent != nil {
+		t.Errorf("speech = %+v, want one step without percent from zero", speech)
+	}
+
+	want := []string{
+		"Показатель «Внимание» увеличился на 50% (10 → 15)",
+		"Показатель «Речь» увеличился на 4 (0 → 4)",
+		"Показатель «Зрительное  восприятие» не изменился (7)",
+		"Показатель «Память» измерен только один раз",
+		"Показатель «Моторика» измерен только один раз",
+	}
+	if !slices.Equal(cmp.Conclusions, want) {
+		t.Errorf("conclusions = %q, want %q", cmp.Conclusions, want)
+	}
+}
+
+func TestCompareConclusionsEN(t *testing.T) {
+	cmp, err := Compare([]file.DiagnosticReport{
+		{ChildID: "c", TestDate: day(1), Indicators: []file.Indicator{{Name: "Memory", Score: 12}}},
+		{ChildID: "c", TestDate: day(2), Indicators: []file.Indicator{{Name: "Memory", Score: 9.5}}},
+	}, LanguageEN)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `Indicator "Memory" decreased by 20.8% (12 → 9.5)`; cmp.Conclusions[0] != want {
+		t.Errorf("conclusion = %q, want %q", cmp.Conclusions[0], want)
+	}
+}
+
+func TestCompareRejects(t *testing.T) {
+	one := file.DiagnosticReport{ChildID: "a", TestDate: day(1)}
+	if _, err := Compare([]file.DiagnosticReport{one}, LanguageRU); !errors.Is(err, ErrNotEnoughReports) {
+		t.Errorf("one report: err = %v, want ErrNotEnoughReports", err)
+	}
+	other := file.DiagnosticReport{ChildID: "b", TestDate: day(2)}
+	if _, err := Compare([]file.DiagnosticReport{one, other}, LanguageRU); !errors.Is(err, ErrDifferentChild) {
+		t.Errorf("different children: err = %v, want ErrDifferentChild", err)
+	}
+}
+
+func TestFormatNumber(t *testing.T) {
+	tests := []struct {
+		v       float64
+		decimal byte
+		want    string
+	}{
+		{12, ',', "12"},
+		{12.54, ',', "12,5"},
+		{12.56, '.', "12.6"},
+		{1234.5, ',', "1234,5"},
+	}
+	for _, tt := range tests {
+		if got := FormatNumber(tt.v, tt.decimal); got != tt.want {
+			t.Errorf("FormatNumber(%v, %q) = %q, want %q", tt.v, tt.decimal, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,167 @@
+package report
+
+import (
+	"encoding/csv"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Caritas-Team/reviewer/internal/usecase/file"
+)
+
+// utf8BOM — метка порядка байтов, по которой Excel распознаёт UTF-8
+var utf8BOM = []byte{0xEF, 0xBB, 0xBF}
+
+// dateLayout — формат дат в заголовках столбцов
+const dateLayout = "02.01.2006"
+
+type csvHeaders struct {
+	indicator, norm, change, changePercent, trend string
+	trends                                        map[Trend]string
+}
+
+var headers = map[Language]csvHeaders{
+	LanguageRU: {
+		indicator:     "Показатель",
+		norm:          "Норма",
+		change:        "Изменение",
+		changePercent: "Изменение, %",
+		trend:         "Динамика",
+		trends:        map[Trend]string{TrendUp: "рост", TrendDown: "снижение", TrendSame: "без изменений"},
+	},
+	LanguageEN: {
+		indicator:     "Indicator",
+		norm:          "Norm",
+		change:        "Change",
+		changePercent: "Change, %",
+		trend:         "Trend",
+		trends:        map[Trend]string{TrendUp: "up", TrendDown: "down", TrendSame: "unchanged"},
+	},
+}
+
+// CSVOptions — параметры выгрузки CSV
+type CSVOptions struct {
+	Language Language
+	// BOM добавляет UTF-8 BOM, чтобы Excel правильно открыл кириллические заголовки
+	BOM bool
+}
+
+// WriteCSV выгружает показатели в CSV по RFC 4180: по столбцу баллов на каждую дату
+// и, если заключений несколько, итоговое изменение от первого к последнему
+func WriteCSV(w io.Writer, reports []file.DiagnosticReport, cmp *Comparison, opts CSVOptions) error {
+	h, ok := headers[opts.Language]
+	if !ok {
+		h = headers[LanguageRU]
+	}
+	if opts.BOM {
+		if _, err := w.Write(utf8BOM); err != nil {
+			return err
+		}
+	}
+
+	cw := csv.NewWriter(w)
+	cw.UseCRLF = true
+
+	table := BuildTable(reports, cmp)
+	header := []string{h.indicator, h.norm}
+	for _, d := range table.Dates {
+		header = append(header, d.Format(dateLayout))
+	}
+	if cmp != nil {
+		header = append(header, h.change, h.changePercent, h.trend)
+	}
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+
+	for _, tr := range table.Rows {
+		row := []string{csvText(tr.Name), csvText(tr.Norm)}
+		for _, score := range tr.Scores {
+			if score == nil {
+				row = append(row, "")
+				continue
+			}
+			row = append(row, formatCSVNumber(*score))
+		}
+		if cmp != nil {
+			if tr.Total == nil {
+				row = append(row, "", "", "")
+			} else {
+				percent := ""
+				if tr.Total.Percent != nil {
+					percent = formatCSVNumber(*tr.Total.Percent)
+				}
+				row = append(row, formatCSVNumber(tr.Total.Absolute), percent, h.trends[tr.Total.Trend])
+			}
+		}
+		if err := cw.Write(row); err != nil {
+			return err
+		}
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+// TableRow — строка сводной таблицы: показатель, норма и баллы по датам
+type TableRow struct {
+	Name   string
+	Norm   string
+	Scores []*float64
+	Total  *Delta
+}
+
+// Table — сводная таблица показателей, общая для выгрузок CSV и PDF
+type Table struct {
+	Dates []time.Time
+	Rows  []TableRow
+}
+
+// BuildTable строит таблицу из сравнения или, если сравнения нет, из первого заключения.
+// Нормы берутся из заключений, где они указаны.
+func BuildTable(reports []file.DiagnosticReport, cmp *Comparison) Table {
+	var t Table
+	switch {
+	case cmp != nil:
+		t.Dates = cmp.Dates
+		for _, ic := range cmp.Indicators {
+			t.Rows = append(t.Rows, TableRow{Name: ic.Name, Scores: ic.Scores, Total: ic.Total})
+		}
+	case len(reports) > 0:
+		r := reports[0]
+		t.Dates = []time.Time{r.TestDate}
+		for _, ind := range r.Indicators {
+			score := ind.Score
+			t.Rows = append(t.Rows, TableRow{Name: ind.Name, Scores: []*float64{&score}})
+		}
+	}
+
+	norms := make(map[string]string)
+	for _, r := range reports {
+		for _, ind := range r.Indicators {
+			if ind.Norm != "" {
+				norms[normalizeName(ind.Name)] = ind.Norm
+			}
+		}
+	}
+	for i := range t.Rows {
+		t.Rows[i].Norm = norms[normalizeName(t.Rows[i].Name)]
+	}
+	return t
+}
+
+// csvText экранирует текст из заключения, чтобы Excel не принял его за формулу (CSV injection):
+// ячейка, начинающаяся с =, +, -, @, табуляции или CR, получает префикс «'». Числовые ячейки
+// с баллами формирует formatCSVNumber, их это не касается.
+func csvText(s string) string {
+	if s != "" && strings.ContainsRune("=+-@\t\r", rune(s[0])) {
+		return "'" + s
+	}
+	return s
+}
+
+func formatCSVNumber(v float64) string {
+	return strconv.FormatFloat(math.Round(v*100)/100, 'f', -1, 64)
+}
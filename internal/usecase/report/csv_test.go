@@ -0,0 +1,76 @@
+package report
+
+import (
+	"bytes"
+	"encoding/csv"
+	"testing"
+	"time"
+
+	"github.com/Caritas-Team/reviewer/internal/usecase/file"
+)
+
+func TestWriteCSVEscapesFormulas(t *testing.T) {
+	report := file.DiagnosticReport{
+		ChildID:  "ID-0042",
+		TestDate: time.Date(2025, 1, 10, 0, 0, 0, 0, time.UTC),
+		Indicators: []file.Indicator{
+			{Name: `=HYPERLINK("http://evil.example","x")`, Score: 1, Norm: "10-15"},
+			{Name: "+cmd", Score: -3, Norm: "-5-5"},
+			{Name: "-Память", Score: 2.5, Norm: "@SUM(A1)"},
+			{Name: "\tВнимание", Score: 4, Norm: "1"},
+			{Name: "Речь", Score: 5, Norm: "≥ 9"},
+		},
+	}
+
+	var buf bytes.Buffer
+	if err := WriteCSV(&buf, []file.DiagnosticReport{report}, nil, CSVOptions{Language: LanguageEN}); err != nil {
+		t.Fatal(err)
+	}
+	records, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := [][]string{
+		{"Indicator", "Norm", "10.01.2025"},
+		{`'=HYPERLINK("http://evil.example","x")`, "10-15", "1"},
+		{"'+cmd", "'-5-5", "-3"},
+		{"'-Память", "'@SUM(A1)", "2.5"},
+		{"'\tВнимание", "1", "4"},
+		{"Речь", "≥ 9", "5"},
+	}
+	if len(records) != len(want) {
+		t.Fatalf("got %d records, want %d: %q", len(records), len(want), records)
+	}
+	for i := range want {
+		if len(records[i]) != len(want[i]) {
+			t.Errorf("record %d = %q, want %q", i, records[i], want[i])
+			continue
+		}
+		for j := range want[i] {
+			if records[i][j] != want[i][j] {
+				t.Errorf("record %d cell %d = %q, want %q", i, j, records[i][j], want[i][j])
+			}
+		}
+	}
+}
+
+func TestCSVText(t *testing.T) {
+	tests := map[string]string{
+		"":          "",
+		"Внимание":  "Внимание",
+		"=1+1":      "'=1+1",
+		"+1":        "'+1",
+		"-1":        "'-1",
+		"@A1":       "'@A1",
+		"\tA":       "'\tA",
+		"\rA":       "'\rA",
+		"10 = 10":   "10 = 10",
+		"'=already": "'=already",
+	}
+	for in, want := range tests {
+		if got := csvText(in); got != want {
+			t.Errorf("csvText(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
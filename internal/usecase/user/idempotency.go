@@ -0,0 +1,127 @@
+package user
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/Caritas-Team/reviewer/internal/config"
+	"github.com/Caritas-Team/reviewer/internal/memecached"
+	"github.com/bradfitz/gomemcache/memcache"
+)
+
+// ClientIPHeader — заголовок, в котором фронтенд передаёт адрес клиента
+const ClientIPHeader = "X-Client-IP"
+
+// idempotencyKeyPrefix — префикс ключей резервирования X-Operation-Key
+const idempotencyKeyPrefix = "idempotency:"
+
+var ErrKeyConflict = errors.New("operation key is already used for another request")
+
+// reservation — то, что закреплено за ключом идемпотентности
+type reservation struct {
+	Fingerprint string   `json:"fingerprint"`
+	IDs         []string `json:"ids"`
+}
+
+// reservationStore — хранилище с атомарной вставкой (семантика memcached add)
+type reservationStore interface {
+	Add(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	Get(ctx context.Context, key string) ([]byte, error)
+	Delete(ctx context.Context, key string) error
+}
+
+// Idempotency закрепляет X-Operation-Key за конкретным набором файлов и клиентом
+type Idempotency struct {
+	store reservationStore
+	ttl   time.Duration
+}
+
+// NewIdempotency хранит резервирования в кэше, как и операции: в memcached или в памяти процесса
+func NewIdempotency(cfg config.Config, cache *memecached.Cache) *Idempotency {
+	return &Idempotency{store: cache, ttl: time.Duration(cfg.Memcached.DefaultTTL) * time.Second}
+}
+
+// Reserve атомарно закрепляет ключ за отпечатком запроса и идентификаторами операций.
+// Повтор с тем же отпечатком возвращает исходные идентификаторы и replay = true,
+// другой отпечаток под тем же ключом — ErrKeyConflict.
+func (i *Idempotency) Reserve(ctx context.Context, key, fingerprint string, ids []string) (stored []string, replay bool, err error) {
+	data, err := json.Marshal(reservation{Fingerprint: fingerprint, IDs: ids})
+	if err != nil {
+		return nil, false, fmt.Errorf("encode reservation: %w", err)
+	}
+	storeKey := idempotencyKeyPrefix + hashKey(key)
+
+	// Вторая попытка нужна, если чужая запись истекла между Add и Get
+	for attempt := 0; attempt < 2; attempt++ {
+		err = i.store.Add(ctx, storeKey, data, i.ttl)
+		if err == nil {
+			return ids, false, nil
+		}
+		if !errors.Is(err, memcache.ErrNotStored) {
+			return nil, false, err
+		}
+
+		existing, err := i.store.Get(ctx, storeKey)
+		if errors.Is(err, memcache.ErrCacheMiss) {
+			continue
+		}
+		if err != nil {
+			return nil, false, err
+		}
+		var r reservation
+		if err := json.Unmarshal(existing, &r); err != nil {
+			return nil, false, fmt.Errorf("decode reservation: %w", err)
+		}
+		if r.Fingerprint != fingerprint {
+			return nil, false, ErrKeyConflict
+		}
+		return r.IDs, true, nil
+	}
+	return nil, false, ErrKeyConflict
+}
+
+// Release снимает резервирование, если запрос не удалось довести до конца, чтобы повтор
+// с тем же ключом выполнился заново, а не вернул идентификаторы несохранённых операций
+func (i *Idempotency) Release(ctx context.Context, key string) error {
+	err := i.store.Delete(ctx, idempotencyKeyPrefix+hashKey(key))
+	if errors.Is(err, memcache.ErrCacheMiss) {
+		return nil
+	}
+	return err
+}
+
+// Fingerprint — отпечаток запроса: SHA-256 от клиента и SHA-256 содержимого каждого файла по порядку
+func Fingerprint(client string, fileSums [][]byte) string {
+	h := sha256.New()
+	h.Write([]byte(client))
+	for _, sum := range fileSums {
+		h.Write([]byte{0})
+		h.Write(sum)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// ClientIP определяет адрес клиента: X-Client-IP, иначе адрес соединения
+func ClientIP(r *http.Request) string {
+	if ip := r.Header.Get(ClientIPHeader); ip != "" {
+		return ip
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// hashKey приводит произвольный клиентский ключ к допустимому для memcached виду
+func hashKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,69 @@
+package user
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/Caritas-Team/reviewer/internal/config"
+	"github.com/Caritas-Team/reviewer/internal/memecached"
+)
+
+func newTestIdempotency(t *testing.T) *Idempotency {
+	t.Helper()
+	cfg := config.Config{Memcached: config.Memcached{DefaultTTL: 60}}
+	cache, err := memecached.NewCache(context.Background(), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = cache.Close() })
+	return NewIdempotency(cfg, cache)
+}
+
+func TestIdempotencyReserve(t *testing.T) {
+	i := newTestIdempotency(t)
+	ctx := context.Background()
+	sums := [][]byte{{1}, {2}}
+	fingerprint := Fingerprint("192.0.2.1", sums)
+
+	ids, replay, err := i.Reserve(ctx, "k", fingerprint, []string{"a", "b"})
+	if err != nil || replay || !slices.Equal(ids, []string{"a", "b"}) {
+		t.Fatalf("first Reserve = %v, %v, %v", ids, replay, err)
+	}
+	ids, replay, err = i.Reserve(ctx, "k", fingerprint, []string{"c", "d"})
+	if err != nil || !replay || !slices.Equal(ids, []string{"a", "b"}) {
+		t.Fatalf("replay = %v, %v, %v; want the original ids", ids, replay, err)
+	}
+	if _, _, err := i.Reserve(ctx, "k", Fingerprint("192.0.2.1", sums[:1]), []string{"e"}); !errors.Is(err, ErrKeyConflict) {
+		t.Errorf("different fingerprint: err = %v, want ErrKeyConflict", err)
+	}
+
+	// После Release ключ свободен и закрепляется за новым запросом
+	if err := i.Release(ctx, "k"); err != nil {
+		t.Fatal(err)
+	}
+	if err := i.Release(ctx, "k"); err != nil {
+		t.Errorf("second Release = %v, want nil", err)
+	}
+	ids, replay, err = i.Reserve(ctx, "k", "other", []string{"e"})
+	if err != nil || replay || !slices.Equal(ids, []string{"e"}) {
+		t.Errorf("Reserve after Release = %v, %v, %v", ids, replay, err)
+	}
+}
+
+func TestFingerprint(t *testing.T) {
+	base := Fingerprint("192.0.2.1", [][]byte{{1}, {2}})
+	for name, other := range map[string]string{
+		"client":     Fingerprint("192.0.2.2", [][]byte{{1}, {2}}),
+		"file order": Fingerprint("192.0.2.1", [][]byte{{2}, {1}}),
+		"file split": Fingerprint("192.0.2.1", [][]byte{{1, 2}}),
+	} {
+		if other == base {
+			t.Errorf("%s does not change the fingerprint", name)
+		}
+	}
+	if Fingerprint("192.0.2.1", [][]byte{{1}, {2}}) != base {
+		t.Error("fingerprint is not deterministic")
+	}
+}
@@ -1 +1,276 @@
 package user
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Caritas-Team/reviewer/internal/config"
+	"github.com/Caritas-Team/reviewer/internal/memecached"
+	"github.com/Caritas-Team/reviewer/internal/metrics"
+	"github.com/bradfitz/gomemcache/memcache"
+)
+
+const (
+	// operationKeyHeader — ключ операции, по которому в первую очередь узнаём клиента
+	operationKeyHeader = "X-Operation-Key"
+
+	// rateLimitKeyPrefix — префикс ключей состояния лимитера в кэше
+	rateLimitKeyPrefix = "ratelimit:"
+
+	// casAttempts — сколько раз повторять обновление ведра при конкурентной записи
+	casAttempts = 10
+
+	// casBackoff — верхняя граница случайной паузы перед повтором, растёт с номером попытки
+	casBackoff = 2 * time.Millisecond
+
+	StorageMemory    = "memory"
+	StorageMemcached = "memcached"
+)
+
+// bucket — состояние token bucket одного клиента
+type bucket struct {
+	Tokens  float64   `json:"tokens"`
+	Updated time.Time `json:"updated"`
+}
+
+// decision — результат попытки списать токен
+type decision struct {
+	allowed    bool
+	remaining  int
+	retryAfter time.Duration
+	reset      time.Duration
+}
+
+// take пополняет ведро за прошедшее время и пытается списать один токен
+func (b *bucket) take(now time.Time, capacity, rate float64) decision {
+	if b.Updated.IsZero() {
+		b.Tokens = capacity
+	} else if elapsed := now.Sub(b.Updated).Seconds(); elapsed > 0 {
+		b.Tokens = math.Min(capacity, b.Tokens+elapsed*rate)
+	}
+	b.Updated = now
+
+	d := decision{}
+	if b.Tokens >= 1 {
+		b.Tokens--
+		d.allowed = true
+	} else {
+		d.retryAfter = seconds((1 - b.Tokens) / rate)
+	}
+	d.remaining = int(b.Tokens)
+	d.reset = seconds((capacity - b.Tokens) / rate)
+	return d
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
+
+// bucketStore хранит вёдра клиентов и атомарно (насколько позволяет бэкенд) списывает токены
+type bucketStore interface {
+	take(ctx context.Context, key string, now time.Time, capacity, rate float64) (decision, error)
+}
+
+// RateLimiter — HTTP-ограничитель запросов по клиенту на основе token bucket
+type RateLimiter struct {
+	limits atomic.Pointer[rateLimits]
+	store  bucketStore
+}
+
+// rateLimits — лимиты, которые можно поменять без перезапуска
+type rateLimits struct {
+	enabled  bool
+	limit    int
+	capacity float64
+	rate     float64
+}
+
+func newRateLimits(rl config.RateLimiter) *rateLimits {
+	return &rateLimits{
+		enabled:  rl.Enabled && rl.RequestsPerMinute > 0,
+		limit:    rl.RequestsPerMinute,
+		capacity: float64(rl.RequestsPerMinute),
+		rate:     float64(rl.RequestsPerMinute) / 60,
+	}
+}
+
+// NewRateLimiter создаёт лимитер; storage: memcached требует включённого memcached
+func NewRateLimiter(cfg config.Config, cache *memecached.Cache) *RateLimiter {
+	rl := cfg.RateLimiter
+	limiter := &RateLimiter{}
+	limiter.limits.Store(newRateLimits(rl))
+	refill := time.Minute
+
+	switch rl.Storage {
+	case StorageMemcached:
+		if cfg.Memcached.Enable {
+			limiter.store = &memcachedBuckets{cache: cache, ttl: refill}
+			break
+		}
+		slog.Warn("rate limiter storage memcached requires memcached.enable, using memory")
+		limiter.store = newMemoryBuckets(refill)
+	case StorageMemory, "":
+		limiter.store = newMemoryBuckets(refill)
+	default:
+		slog.Warn("unknown rate limiter storage, using memory", "storage", rl.Storage)
+		limiter.store = newMemoryBuckets(refill)
+	}
+	return limiter
+}
+
+// Update применяет новые enabled и requests_per_minute; хранилище меняется только перезапуском.
+// Накопленные токены сохраняются и при следующем запросе обрезаются до новой ёмкости.
+func (l *RateLimiter) Update(rl config.RateLimiter) {
+	l.limits.Store(newRateLimits(rl))
+}
+
+// Middleware отклоняет запросы сверх лимита с 429 и заголовками Retry-After и X-RateLimit-*
+func (l *RateLimiter) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		limits := l.limits.Load()
+		if !limits.enabled {
+			next.ServeHTTP(w, r)
+			return
+		}
+		d, err := l.store.take(r.Context(), ClientIdentity(r), time.Now(), limits.capacity, limits.rate)
+		if err != nil {
+			// Недоступное хранилище лимитов не должно останавливать сервис
+			slog.WarnContext(r.Context(), "rate limiter storage failed, request allowed", "err", err)
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		h := w.Header()
+		h.Set("X-RateLimit-Limit", strconv.Itoa(limits.limit))
+		h.Set("X-RateLimit-Remaining", strconv.Itoa(d.remaining))
+		h.Set("X-RateLimit-Reset", strconv.Itoa(int(math.Ceil(d.reset.Seconds()))))
+
+		if !d.allowed {
+			metrics.UpdateRateLimitExceeded()
+			h.Set("Retry-After", strconv.Itoa(int(math.Ceil(d.retryAfter.Seconds()))))
+			h.Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusTooManyRequests)
+			_, _ = w.Write([]byte(`{"error":"rate limit exceeded"}` + "\n"))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// ClientIdentity определяет клиента: ключ операции, затем X-Client-IP, затем адрес соединения
+func ClientIdentity(r *http.Request) string {
+	if key := r.Header.Get(operationKeyHeader); key != "" {
+		return "key:" + key
+	}
+	return "ip:" + ClientIP(r)
+}
+
+// memoryBuckets — вёдра в памяти процесса
+type memoryBuckets struct {
+	mu        sync.Mutex
+	items     map[string]*bucket
+	idle      time.Duration
+	lastSweep time.Time
+}
+
+func newMemoryBuckets(idle time.Duration) *memoryBuckets {
+	return &memoryBuckets{items: make(map[string]*bucket), idle: idle, lastSweep: time.Now()}
+}
+
+func (m *memoryBuckets) take(ctx context.Context, key string, now time.Time, capacity, rate float64) (decision, error) {
+	if err := ctx.Err(); err != nil {
+		return decision{}, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// Ведро, простоявшее дольше полного пополнения, ничем не отличается от нового
+	if now.Sub(m.lastSweep) >= m.idle {
+		for k, b := range m.items {
+			if now.Sub(b.Updated) >= m.idle {
+				delete(m.items, k)
+			}
+		}
+		m.lastSweep = now
+	}
+
+	b, ok := m.items[key]
+	if !ok {
+		b = &bucket{}
+		m.items[key] = b
+	}
+	return b.take(now, capacity, rate), nil
+}
+
+// memcachedBuckets — вёдра в memcached, общие для всех реплик
+type memcachedBuckets struct {
+	cache *memecached.Cache
+	ttl   time.Duration
+}
+
+func (m *memcachedBuckets) take(ctx context.Context, key string, now time.Time, capacity, rate float64) (decision, error) {
+	storeKey := rateLimitKeyPrefix + hashKey(key)
+
+	// Реплики меняют одно ведро конкурентно: новое создаём через Add, существующее — через CAS
+	for attempt := 0; attempt < casAttempts; attempt++ {
+		if attempt > 0 {
+			// Случайная пауза разводит реплики, одновременно пишущие одно ведро
+			select {
+			case <-ctx.Done():
+				return decision{}, ctx.Err()
+			case <-time.After(rand.N(casBackoff * time.Duration(attempt))):
+			}
+		}
+
+		var d decision
+		err := m.cache.CompareAndSwap(ctx, storeKey, m.ttl, func(current []byte) ([]byte, error) {
+			var b bucket
+			if err := json.Unmarshal(current, &b); err != nil {
+				return nil, fmt.Errorf("decode bucket: %w", err)
+			}
+			d = b.take(now, capacity, rate)
+			return encodeBucket(b)
+		})
+		switch {
+		case err == nil:
+			return d, nil
+		case errors.Is(err, memcache.ErrCASConflict):
+			continue
+		case !errors.Is(err, memcache.ErrCacheMiss):
+			return decision{}, err
+		}
+
+		var b bucket
+		d = b.take(now, capacity, rate)
+		data, err := encodeBucket(b)
+		if err != nil {
+			return decision{}, err
+		}
+		err = m.cache.Add(ctx, storeKey, data, m.ttl)
+		switch {
+		case err == nil:
+			return d, nil
+		case !errors.Is(err, memcache.ErrNotStored):
+			return decision{}, err
+		}
+	}
+	return decision{}, fmt.Errorf("update bucket after %d attempts: %w", casAttempts, memcache.ErrCASConflict)
+}
+
+func encodeBucket(b bucket) ([]byte, error) {
+	data, err := json.Marshal(b)
+	if err != nil {
+		return nil, fmt.Errorf("encode bucket: %w", err)
+	}
+	return data, nil
+}
@@ -0,0 +1,149 @@
+package user
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Caritas-Team/reviewer/internal/config"
+	"github.com/Caritas-Team/reviewer/internal/memecached"
+	"github.com/Caritas-Team/reviewer/internal/memecached/memecachedtest"
+)
+
+// newReplica возвращает вёдра одной реплики сервиса поверх общего memcached
+func newReplica(t *testing.T, srv *memecachedtest.Server) *memcachedBuckets {
+	t.Helper()
+	cache, err := memecached.NewCache(context.Background(), config.Config{Memcached: config.Memcached{
+		Enable:          true,
+		Servers:         []string{srv.Addr()},
+		KeyPrefix:       "test",
+		ConnectAttempts: 1,
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = cache.Close() })
+	return &memcachedBuckets{cache: cache, ttl: time.Minute}
+}
+
+func TestClientIdentity(t *testing.T) {
+	tests := []struct {
+		name    string
+		headers map[string]string
+		remote  string
+		want    string
+	}{
+		{name: "operation key first", headers: map[string]string{operationKeyHeader: "k", ClientIPHeader: "10.0.0.1"},
+			remote: "192.0.2.1:5000", want: "key:k"},
+		{name: "client ip header", headers: map[string]string{ClientIPHeader: "10.0.0.1"}, remote: "192.0.2.1:5000",
+			want: "ip:10.0.0.1"},
+		{name: "connection address", remote: "192.0.2.1:5000", want: "ip:192.0.2.1"},
+		{name: "address without port", remote: "192.0.2.1", want: "ip:192.0.2.1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.RemoteAddr = tt.remote
+			for k, v := range tt.headers {
+				r.Header.Set(k, v)
+			}
+			if got := ClientIdentity(r); got != tt.want {
+				t.Errorf("ClientIdentity = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRateLimiterMiddleware(t *testing.T) {
+	limiter := NewRateLimiter(config.Config{RateLimiter: config.RateLimiter{Enabled: true, RequestsPerMinute: 2}}, nil)
+	handler := limiter.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	request := func(key string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Header.Set(operationKeyHeader, key)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w
+	}
+
+	for i, want := range []string{"1", "0"} {
+		w := request("a")
+		if w.Code != http.StatusOK || w.Header().Get("X-RateLimit-Remaining") != want {
+			t.Fatalf("request %d: %d, remaining %q; want 200, %s", i+1, w.Code, w.Header().Get("X-RateLimit-Remaining"), want)
+		}
+	}
+	w := request("a")
+	if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") != "30" {
+		t.Fatalf("over limit: %d, Retry-After %q; want 429, 30", w.Code, w.Header().Get("Retry-After"))
+	}
+	if w.Header().Get("X-RateLimit-Limit") != "2" {
+		t.Errorf("X-RateLimit-Limit = %q, want 2", w.Header().Get("X-RateLimit-Limit"))
+	}
+	if w := request("b"); w.Code != http.StatusOK {
+		t.Errorf("another key: %d, want its own bucket", w.Code)
+	}
+
+	limiter.Update(config.RateLimiter{Enabled: false, RequestsPerMinute: 2})
+	if w := request("a"); w.Code != http.StatusOK || w.Header().Get("X-RateLimit-Limit") != "" {
+		t.Errorf("disabled limiter: %d, headers %v", w.Code, w.Header())
+	}
+}
+
+func TestMemcachedBucketsShareLimit(t *testing.T) {
+	srv := memecachedtest.Start(t)
+	replicas := []*memcachedBuckets{newReplica(t, srv), newReplica(t, srv)}
+	ctx := context.Background()
+	now := time.Now()
+
+	allowed := 0
+	for i := range 6 {
+		d, err := replicas[i%2].take(ctx, "key:k", now, 3, 3.0/60)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if d.allowed {
+			allowed++
+		}
+	}
+	if allowed != 3 {
+		t.Errorf("allowed %d requests across replicas, want 3", allowed)
+	}
+}
+
+func TestMemcachedBucketsRetryOnConflict(t *testing.T) {
+	srv := memecachedtest.Start(t)
+	a, b := newReplica(t, srv), newReplica(t, srv)
+	ctx := context.Background()
+	now := time.Now()
+
+	// Первое обращение создаёт ведро через Add
+	if d, err := a.take(ctx, "key:k", now, 5, 5.0/60); err != nil || d.remaining != 4 {
+		t.Fatalf("first take = %+v, %v; want 4 remaining", d, err)
+	}
+
+	// Между чтением и CAS реплики a ведро успевает изменить реплика b
+	var raced atomic.Bool
+	var other decision
+	srv.OnStore(func(string) {
+		if raced.CompareAndSwap(false, true) {
+			var err error
+			if other, err = b.take(ctx, "key:k", now, 5, 5.0/60); err != nil {
+				t.Error(err)
+			}
+		}
+	})
+	readsBefore := srv.Reads()
+	d, err := a.take(ctx, "key:k", now, 5, 5.0/60)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if other.remaining != 3 || d.remaining != 2 {
+		t.Errorf("remaining: b = %d, a = %d; want 3 and 2 — the retry must see b's token", other.remaining, d.remaining)
+	}
+	// Чтение a, чтение b и повторное чтение a после конфликта
+	if reads := srv.Reads() - readsBefore; reads != 3 {
+		t.Errorf("%d reads, want 3", reads)
+	}
+}